@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The API answers the same paths in server mode and from a static build:
+//
+//	/api/posts.json              first page of post summaries, newest first
+//	/api/posts/page/<n>.json     later pages
+//	/api/posts/<slug>.json       one post with its rendered content and TOC
+//	/api/collections.json        the collection tree
+//
+// The server also accepts /api/posts?page=<n>, /api/posts/<slug> and
+// /api/collections. Drafts and scheduled posts are never listed.
+
+// APIPost is the metadata-only view of a post served by /api/posts.
+type APIPost struct {
+	Slug              string   `json:"slug"`
+	Title             string   `json:"title"`
+	Date              string   `json:"date"`
+	Description       string   `json:"description"` // plain text
+	Collection        string   `json:"collection"`
+	Tags              []string `json:"tags"`
+	ReadTimeInMinutes int      `json:"readTimeInMinutes"`
+	URL               string   `json:"url"`
+}
+
+// APIPostDetail adds the rendered body and TOC for /api/posts/<slug>.
+type APIPostDetail struct {
+	APIPost
+	Content string    `json:"content"`
+	TOC     []TOCItem `json:"toc"`
+}
+
+// APIPostsPage is one page of /api/posts. Next and Prev are absolute URLs,
+// empty on the last and first page.
+type APIPostsPage struct {
+	Posts      []APIPost `json:"posts"`
+	Page       int       `json:"page"`
+	PerPage    int       `json:"perPage"`
+	Total      int       `json:"total"`
+	TotalPages int       `json:"totalPages"`
+	Next       string    `json:"next,omitempty"`
+	Prev       string    `json:"prev,omitempty"`
+}
+
+// APICollectionsData mirrors CollectionsData: the root collections, each
+// with its sub-collections.
+type APICollectionsData struct {
+	Title       string          `json:"title"`
+	Collections []APICollection `json:"collections"`
+}
+
+type APICollection struct {
+	Slug            string          `json:"slug"`
+	Title           string          `json:"title"`
+	Description     string          `json:"description"` // plain text
+	Parent          string          `json:"parent,omitempty"`
+	Planned         int             `json:"planned,omitempty"`
+	Status          string          `json:"status,omitempty"`
+	PercentComplete int             `json:"percentComplete"`
+	URL             string          `json:"url"`
+	Posts           []APIPost       `json:"posts"`
+	Children        []APICollection `json:"children,omitempty"`
+}
+
+func newAPIPost(post Post, baseURL string) APIPost {
+	date := post.RawDate
+	if !post.Published.IsZero() {
+		date = post.Published.Format(time.RFC3339)
+	}
+	tags := post.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return APIPost{
+		Slug:              post.Slug,
+		Title:             post.Title,
+		Date:              date,
+		Description:       stripHTML(string(post.Description)),
+		Collection:        post.Collection,
+		Tags:              tags,
+		ReadTimeInMinutes: post.ReadTimeInMinutes,
+		URL:               canonicalURL(baseURL, "/post/"+post.Slug),
+	}
+}
+
+func newAPIPostDetail(post Post, baseURL string) APIPostDetail {
+	return APIPostDetail{
+		APIPost: newAPIPost(post, baseURL),
+		Content: string(withBasePath(post.Content)),
+		TOC:     post.TOC,
+	}
+}
+
+// apiPostsPath is where page n of the post list lives.
+func apiPostsPath(n int) string {
+	if n == 1 {
+		return "/api/posts.json"
+	}
+	return fmt.Sprintf("/api/posts/page/%d.json", n)
+}
+
+// apiPageCount is how many pages of API_PAGE_SIZE the posts fill; an empty
+// site still has one empty page.
+func apiPageCount(posts []Post) int {
+	return max(1, (len(posts)+site.APIPageSize-1)/site.APIPageSize)
+}
+
+// newAPIPostsPage returns page n (from 1) of posts. ok is false when n is
+// past the last page.
+func newAPIPostsPage(posts []Post, n int, baseURL string) (page APIPostsPage, ok bool) {
+	pages := apiPageCount(posts)
+	if n < 1 || n > pages {
+		return APIPostsPage{}, false
+	}
+	start := (n - 1) * site.APIPageSize
+	end := min(start+site.APIPageSize, len(posts))
+	page = APIPostsPage{
+		Posts:      make([]APIPost, 0, end-start),
+		Page:       n,
+		PerPage:    site.APIPageSize,
+		Total:      len(posts),
+		TotalPages: pages,
+	}
+	for _, post := range posts[start:end] {
+		page.Posts = append(page.Posts, newAPIPost(post, baseURL))
+	}
+	if n < pages {
+		page.Next = canonicalURL(baseURL, apiPostsPath(n+1))
+	}
+	if n > 1 {
+		page.Prev = canonicalURL(baseURL, apiPostsPath(n-1))
+	}
+	return page, true
+}
+
+func newAPICollection(c Collection, baseURL string) APICollection {
+	collection := APICollection{
+		Slug:            c.Slug,
+		Title:           c.Title,
+		Description:     c.DescriptionText,
+		Parent:          c.Parent,
+		Planned:         c.Planned,
+		Status:          c.Status,
+		PercentComplete: c.PercentComplete,
+		URL:             canonicalURL(baseURL, "/collection/"+c.Slug),
+		Posts:           make([]APIPost, 0, len(c.Posts)),
+	}
+	for _, post := range c.Posts {
+		collection.Posts = append(collection.Posts, newAPIPost(post, baseURL))
+	}
+	for _, child := range c.Children {
+		collection.Children = append(collection.Children, newAPICollection(child, baseURL))
+	}
+	return collection
+}
+
+func newAPICollectionsData(collections []Collection, baseURL string) APICollectionsData {
+	data := APICollectionsData{Title: "Collections", Collections: []APICollection{}}
+	for _, c := range rootCollections(collections) {
+		data.Collections = append(data.Collections, newAPICollection(c, baseURL))
+	}
+	return data
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// withCORS lets the origins in API_CORS_ORIGINS read the API from a
+// browser and answers their preflight requests.
+func withCORS(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setCORS(w, r)
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// setCORS allows the request's Origin when it is on the allowlist. "*" in
+// the list allows any origin.
+func setCORS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Origin")
+	origin := r.Header.Get("Origin")
+	switch {
+	case origin == "":
+	case slices.Contains(site.APIAllowedOrigins, "*"):
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	case slices.Contains(site.APIAllowedOrigins, origin):
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+}
+
+func handleAPIPosts(w http.ResponseWriter, r *http.Request) {
+	n := 1
+	if value := r.URL.Query().Get("page"); value != "" {
+		var err error
+		if n, err = strconv.Atoi(value); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "page must be a number")
+			return
+		}
+	}
+	servePostsPage(w, r, n)
+}
+
+func servePostsPage(w http.ResponseWriter, r *http.Request, n int) {
+	posts, err := loadPosts()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	page, ok := newAPIPostsPage(posts, n, requestBaseURL(r))
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "page not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func handleAPIPost(w http.ResponseWriter, r *http.Request) {
+	slug := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/posts/"), "/")
+	if slug == "" {
+		handleAPIPosts(w, r)
+		return
+	}
+	if rest, ok := strings.CutPrefix(slug, "page/"); ok {
+		n, err := strconv.Atoi(strings.TrimSuffix(rest, ".json"))
+		if err != nil {
+			writeJSONError(w, http.StatusNotFound, "page not found")
+			return
+		}
+		servePostsPage(w, r, n)
+		return
+	}
+	slug = strings.TrimSuffix(slug, ".json")
+
+	post, err := loadPost(slug)
+	if err != nil || !isPublished(post) {
+		writeJSONError(w, http.StatusNotFound, "post not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newAPIPostDetail(post, requestBaseURL(r)))
+}
+
+func handleAPICollections(w http.ResponseWriter, r *http.Request) {
+	collections, err := loadCollections()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, newAPICollectionsData(collections, requestBaseURL(r)))
+}
+
+// buildAPI writes the API documents under distDir/api at the paths listed
+// above, so clients work against a static deploy too. Static hosts do not
+// send the CORS headers; serve-dist adds them like the server does.
+func buildAPI(rep *buildReport, distDir, baseURL string, posts []Post, collections []Collection) error {
+	write := func(urlPath string, v any) error {
+		outputPath := filepath.Join(distDir, filepath.FromSlash(urlPath))
+		return rep.write("api", outputPath, "", func() error {
+			if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+				return err
+			}
+			data, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			return os.WriteFile(outputPath, append(data, '\n'), 0644)
+		})
+	}
+
+	for n := 1; n <= apiPageCount(posts); n++ {
+		page, _ := newAPIPostsPage(posts, n, baseURL)
+		if err := write(apiPostsPath(n), page); err != nil {
+			return err
+		}
+	}
+	for _, post := range posts {
+		if err := write("/api/posts/"+post.Slug+".json", newAPIPostDetail(post, baseURL)); err != nil {
+			return err
+		}
+	}
+	return write("/api/collections.json", newAPICollectionsData(collections, baseURL))
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"time"
+)
+
+// ArchiveData is the /archive page: posts grouped by year, then month,
+// newest first, plus the posts whose date could not be parsed.
+type ArchiveData struct {
+	Title   string
+	Years   []ArchiveYear
+	Undated []Post
+	PageMeta
+}
+
+type ArchiveYear struct {
+	Year   int
+	Count  int
+	Months []ArchiveMonth
+}
+
+type ArchiveMonth struct {
+	Month time.Month
+	Posts []Post
+}
+
+// groupArchive buckets posts by the year and month of their publish date in
+// the site timezone. Posts without a parseable date go to Undated, keeping
+// their order, instead of being dropped.
+func groupArchive(posts []Post) (years []ArchiveYear, undated []Post) {
+	var dated []Post
+	for _, post := range posts {
+		if post.Published.IsZero() {
+			undated = append(undated, post)
+		} else {
+			dated = append(dated, post)
+		}
+	}
+	sort.SliceStable(dated, func(i, j int) bool {
+		return dated[i].Published.After(dated[j].Published)
+	})
+
+	for _, post := range dated {
+		local := post.Published.In(site.Location)
+		if len(years) == 0 || years[len(years)-1].Year != local.Year() {
+			years = append(years, ArchiveYear{Year: local.Year()})
+		}
+		year := &years[len(years)-1]
+		if len(year.Months) == 0 || year.Months[len(year.Months)-1].Month != local.Month() {
+			year.Months = append(year.Months, ArchiveMonth{Month: local.Month()})
+		}
+		month := &year.Months[len(year.Months)-1]
+		month.Posts = append(month.Posts, post)
+		year.Count++
+	}
+	return years, undated
+}
+
+// newArchiveData builds the archive of posts in the site language, matching
+// the main index.
+func newArchiveData(posts []Post, baseURL string) ArchiveData {
+	years, undated := groupArchive(inLanguage(posts, site.Language))
+	return ArchiveData{
+		Title:    "Archive",
+		Years:    years,
+		Undated:  undated,
+		PageMeta: newPageMeta("archive", baseURL, "/archive"),
+	}
+}
+
+func handleArchive(w http.ResponseWriter, r *http.Request) {
+	if redirectTrailingSlash(w, r) {
+		return
+	}
+	posts, err := loadPosts()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	pages.render(w, "archive", newArchiveData(posts, requestBaseURL(r)))
+}
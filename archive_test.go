@@ -0,0 +1,89 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestGroupArchive(t *testing.T) {
+	saved := site
+	t.Cleanup(func() { site = saved })
+	site.Location = time.UTC
+
+	at := func(slug, date string) Post {
+		published, err := time.Parse(time.RFC3339, date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return Post{Slug: slug, Published: published}
+	}
+	posts := []Post{
+		at("mar-b", "2024-03-05T10:00:00Z"),
+		{Slug: "undated-1"},
+		at("jan-2023", "2023-01-20T10:00:00Z"),
+		at("mar-a", "2024-03-20T10:00:00Z"),
+		// Early on New Year's Day east of UTC is still December in UTC.
+		at("new-year-east", "2024-01-01T01:00:00+02:00"),
+		at("feb", "2024-02-10T10:00:00Z"),
+		{Slug: "undated-2"},
+	}
+
+	years, undated := groupArchive(posts)
+
+	type month struct {
+		year  int
+		month time.Month
+		slugs []string
+	}
+	var got []month
+	var counts []int
+	for _, y := range years {
+		counts = append(counts, y.Count)
+		for _, m := range y.Months {
+			var slugs []string
+			for _, p := range m.Posts {
+				slugs = append(slugs, p.Slug)
+			}
+			got = append(got, month{y.Year, m.Month, slugs})
+		}
+	}
+	want := []month{
+		{2024, time.March, []string{"mar-a", "mar-b"}},
+		{2024, time.February, []string{"feb"}},
+		{2023, time.December, []string{"new-year-east"}},
+		{2023, time.January, []string{"jan-2023"}},
+	}
+	if !slices.EqualFunc(got, want, func(a, b month) bool {
+		return a.year == b.year && a.month == b.month && slices.Equal(a.slugs, b.slugs)
+	}) {
+		t.Errorf("groupArchive months = %v, want %v", got, want)
+	}
+	if !slices.Equal(counts, []int{3, 2}) {
+		t.Errorf("year counts = %v, want [3 2]", counts)
+	}
+	if len(undated) != 2 || undated[0].Slug != "undated-1" || undated[1].Slug != "undated-2" {
+		t.Errorf("undated = %v, want undated-1 and undated-2 in order", undated)
+	}
+}
+
+func TestArchiveLeavesOutHiddenPosts(t *testing.T) {
+	future := time.Now().AddDate(0, 1, 0).Format("2006-01-02")
+	newTestSite(t, map[string]string{
+		"posts/live.html":      testPost("Live", "2024-05-01", "<p>x</p>"),
+		"posts/draft.html":     "<!-- draft: true -->\n" + testPost("Draft", "2024-05-02", "<p>x</p>"),
+		"posts/scheduled.html": testPost("Scheduled", future, "<p>x</p>"),
+		"posts/undated.html":   "<!-- title: Undated -->\n<!-- date: soon -->\n<p>x</p>\n",
+	})
+	posts, err := loadPosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := newArchiveData(posts, "https://example.com")
+	if len(data.Years) != 1 || len(data.Years[0].Months) != 1 || len(data.Years[0].Months[0].Posts) != 1 || data.Years[0].Months[0].Posts[0].Slug != "live" {
+		t.Errorf("archive years = %+v, want only live", data.Years)
+	}
+	if len(data.Undated) != 1 || data.Undated[0].Slug != "undated" {
+		t.Errorf("archive undated = %v, want only undated", data.Undated)
+	}
+}
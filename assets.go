@@ -0,0 +1,142 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// assetHashes caches the content hash of each static file, revalidated by
+// size and mtime on every lookup, so a stylesheet edited while the server
+// runs gets a new URL on the next request.
+type assetHashes struct {
+	mu      sync.Mutex
+	entries map[string]assetHash
+}
+
+type assetHash struct {
+	size  int64
+	mtime time.Time
+	hash  string
+}
+
+var staticHashes = &assetHashes{entries: make(map[string]assetHash)}
+
+func (a *assetHashes) get(file string) (string, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return "", err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if e, ok := a.entries[file]; ok && e.size == info.Size() && e.mtime.Equal(info.ModTime()) {
+		return e.hash, nil
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])[:10]
+	a.entries[file] = assetHash{size: info.Size(), mtime: info.ModTime(), hash: hash}
+	return hash, nil
+}
+
+// assetURL is the `asset` template func: the URL of a file under static/
+// with a ?v= content hash, so browsers refetch it whenever it changes. A
+// missing file gets its plain URL.
+func assetURL(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	url := sitePath("/static/" + name)
+	if hash, err := staticHashes.get(paths.content("static", filepath.FromSlash(name))); err == nil {
+		url += "?v=" + hash
+	}
+	return url
+}
+
+// staticHandler serves static/. Versioned requests from assetURL may be
+// cached for a year since their URL changes with their content; plain ones
+// keep the default revalidation.
+func staticHandler() http.Handler {
+	files := http.StripPrefix("/static/", http.FileServer(http.Dir(paths.content("static"))))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		setAssetCaching(w, r)
+		files.ServeHTTP(w, r)
+	})
+}
+
+// setAssetCaching marks responses to versioned asset URLs as immutable.
+func setAssetCaching(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("v") != "" {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	}
+}
+
+// criticalCSSFile is the stylesheet `build -critical-css` inlines into
+// every page, relative to static/.
+const criticalCSSFile = "critical.css"
+
+// inlineCSS is the critical CSS the layout inlines. Only buildStatic sets
+// it, so server mode always links the stylesheets normally.
+var inlineCSS template.CSS
+
+// criticalCSS is the `criticalCSS` template func.
+func criticalCSS() template.CSS {
+	return inlineCSS
+}
+
+// loadCriticalCSS reads static/critical.css for inlining. A missing file
+// leaves nothing to inline and is not an error.
+func loadCriticalCSS() (template.CSS, error) {
+	content, err := os.ReadFile(paths.content("static", criticalCSSFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if strings.Contains(strings.ToLower(string(content)), "</style") {
+		return "", fmt.Errorf("static/%s: contains </style", criticalCSSFile)
+	}
+	return template.CSS(strings.TrimSpace(string(content))), nil
+}
+
+// staticRefs reads the comma-separated list of files under static/ in a
+// post's `styles` or `scripts` metadata. Entries that are not plain
+// relative paths, such as ../../secret or /etc/passwd, could point
+// outside static/ and are dropped with a warning.
+func staticRefs(key, value string) ([]string, []string) {
+	var refs, warnings []string
+	for _, ref := range splitList(value) {
+		ref = strings.TrimPrefix(ref, "./")
+		if !fs.ValidPath(ref) || strings.Contains(ref, `\`) {
+			warnings = append(warnings, fmt.Sprintf("%s: %q is not a path under static/, ignoring it", key, ref))
+			continue
+		}
+		refs = append(refs, ref)
+	}
+	return refs, warnings
+}
+
+// missingStaticRefs lists the post styles and scripts that name no file
+// under static/, which would render as links that 404.
+func missingStaticRefs(posts []Post) []string {
+	var problems []string
+	for _, post := range posts {
+		for _, ref := range append(append([]string(nil), post.ExtraStyles...), post.ExtraScripts...) {
+			if info, err := os.Stat(paths.content("static", filepath.FromSlash(ref))); err != nil || info.IsDir() {
+				problems = append(problems, fmt.Sprintf("post %s: static/%s does not exist", post.Slug, ref))
+			}
+		}
+	}
+	return problems
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type Author struct {
+	Slug  string
+	Name  string
+	Title string
+	Bio   string
+	URL   string
+	Posts []Post
+	PageMeta
+}
+
+// loadAuthor reads the profile authors/<slug>.html. The slug comes from post
+// metadata and request paths, so it must be a single slug segment.
+func loadAuthor(slug string) (Author, error) {
+	if !slugRegex.MatchString(slug) {
+		return Author{}, fmt.Errorf("author %q is not a slug: use only a-z, 0-9 and -", slug)
+	}
+	content, err := os.ReadFile(paths.content("authors", slug+".html"))
+	if err != nil {
+		return Author{}, err
+	}
+
+	meta, _ := parseFrontMatter(string(content))
+	author := Author{
+		Slug: slug,
+		Name: meta["name"],
+		Bio:  meta["bio"],
+		URL:  meta["url"],
+	}
+	if author.Name == "" {
+		author.Name = slug
+	}
+	author.Title = author.Name
+	return author, nil
+}
+
+// loadAuthors returns every profile in authors/, sorted by name. A missing
+// authors/ directory simply means there are no profiles.
+func loadAuthors() ([]Author, error) {
+	var authors []Author
+
+	err := filepath.WalkDir(paths.content("authors"), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".html") {
+			return nil
+		}
+
+		author, err := loadAuthor(strings.TrimSuffix(filepath.Base(path), ".html"))
+		if err != nil {
+			return err
+		}
+		authors = append(authors, author)
+		return nil
+	})
+
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	sort.Slice(authors, func(i, j int) bool {
+		return authors[i].Name < authors[j].Name
+	})
+
+	return authors, nil
+}
+
+// resolveAuthor looks up the profile for slug. Unknown slugs resolve to an
+// Author with an empty Slug whose Name is the raw value, so a plain name used
+// as the default author still renders as a byline.
+func resolveAuthor(slug string) Author {
+	if author, err := loadAuthor(slug); err == nil {
+		return author
+	}
+	return Author{Name: slug}
+}
+
+// authorPosts returns the posts credited to the author with the given slug.
+func authorPosts(slug string, posts []Post) []Post {
+	var result []Post
+	for _, post := range posts {
+		for _, author := range post.Authors {
+			if author.Slug == slug {
+				result = append(result, post)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// validateAuthors reports posts that credit an author by something other
+// than a slug, or an author without a profile. The default author is exempt
+// so sites without authors/ keep building.
+func validateAuthors(posts []Post) error {
+	var errs []error
+	for _, post := range posts {
+		invalid := make(map[string]bool)
+		for _, slug := range splitList(post.Meta["author"]) {
+			if !slugRegex.MatchString(slug) {
+				errs = append(errs, fmt.Errorf("post %q credits author %q, which is not a slug: use only a-z, 0-9 and -", post.Slug, slug))
+				invalid[slug] = true
+			}
+		}
+		for _, author := range post.Authors {
+			if author.Slug == "" && author.Name != site.Author && !invalid[author.Name] {
+				errs = append(errs, fmt.Errorf("post %q references unknown author %q", post.Slug, author.Name))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
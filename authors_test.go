@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLoadAuthorRejectsNonSlugs(t *testing.T) {
+	newTestSite(t, map[string]string{
+		"authors/sam.html": "<!-- name: Sam -->\n",
+		"posts/foo.html":   "<!-- name: Not an author -->\n" + testPost("Foo", "2024-01-01", "<p>x</p>"),
+	})
+	if author, err := loadAuthor("sam"); err != nil || author.Name != "Sam" {
+		t.Errorf(`loadAuthor("sam") = %+v, %v; want Sam`, author, err)
+	}
+	for _, slug := range []string{"../posts/foo", "..", "sam/../sam", `..\posts\foo`, "Sam", ""} {
+		_, err := loadAuthor(slug)
+		if want := fmt.Sprintf("author %q is not a slug", slug); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("loadAuthor(%q) = %v, want %q", slug, err, want)
+		}
+	}
+}
+
+func TestHandleAuthorRejectsTraversal(t *testing.T) {
+	newTestSite(t, map[string]string{
+		"posts/foo.html": "<!-- name: Not an author -->\n" + testPost("Foo", "2024-01-01", "<p>x</p>"),
+	})
+	req := httptest.NewRequest(http.MethodGet, "/author/x", nil)
+	req.URL.Path = "/author/../posts/foo"
+	rec := httptest.NewRecorder()
+	handleAuthor(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status %d, want 404", rec.Code)
+	}
+}
+
+func TestValidateAuthors(t *testing.T) {
+	withSite(t)
+	site.Author = "Default Name"
+	newTestSite(t, map[string]string{
+		"authors/sam.html": "<!-- name: Sam -->\n",
+		"posts/foo.html":   "<!-- name: Not an author -->\n" + testPost("Foo", "2024-01-01", "<p>x</p>"),
+	})
+	post := func(authors string) Post {
+		p := Post{Slug: "p", Meta: map[string]string{"author": authors}}
+		for _, slug := range splitList(authors) {
+			p.Authors = append(p.Authors, resolveAuthor(slug))
+		}
+		if authors == "" {
+			p.Authors = []Author{resolveAuthor(site.Author)}
+		}
+		return p
+	}
+	tests := []struct {
+		authors string
+		want    string
+	}{
+		{"sam", ""},
+		{"", ""},
+		{"nobody", `post "p" references unknown author "nobody"`},
+		{"sam, ../posts/foo", `post "p" credits author "../posts/foo", which is not a slug: use only a-z, 0-9 and -`},
+	}
+	for _, tt := range tests {
+		err := validateAuthors([]Post{post(tt.authors)})
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("author %q: validateAuthors = %v, want nil", tt.authors, err)
+		case tt.want != "" && (err == nil || err.Error() != tt.want):
+			t.Errorf("author %q: validateAuthors = %v, want %q", tt.authors, err, tt.want)
+		}
+	}
+}
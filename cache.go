@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"path/filepath"
+	"sync"
+)
+
+// contentCache keeps every loaded post between calls to loadPosts. Before
+// each use it stats the files under posts/, collections/, authors/ and
+// partials/ and reloads when any of them was added, removed or modified, so
+// editing a post or a partial still shows up on the next refresh.
+// Publication is decided by loadPosts at call time, so scheduled posts appear
+// without a reload.
+type contentCache struct {
+	mu     sync.Mutex
+	stamp  uint64
+	all    []Post
+	loaded bool
+}
+
+var postCache = &contentCache{}
+
+func (c *contentCache) get() ([]Post, error) {
+	stamp, err := contentStamp()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.loaded && c.stamp == stamp {
+		return c.all, nil
+	}
+	all, err := loadAllPosts()
+	if err != nil {
+		return nil, err
+	}
+	c.all, c.stamp, c.loaded = all, stamp, true
+	return all, nil
+}
+
+// version is the content stamp of the posts last returned by get, for
+// caches derived from them.
+func (c *contentCache) version() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stamp
+}
+
+// ready reports whether posts have loaded successfully at least once. It
+// does no I/O, so health checks stay cheap.
+func (c *contentCache) ready() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.loaded
+}
+
+// contentStamp hashes the path, size and mtime of every content file.
+func contentStamp() (uint64, error) {
+	h := fnv.New64a()
+	for _, dir := range []string{"posts", "collections", "authors", "partials"} {
+		err := filepath.WalkDir(paths.content(dir), func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "%s\x00%d\x00%d\x00", path, info.Size(), info.ModTime().UnixNano())
+			return nil
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return 0, err
+		}
+	}
+	return h.Sum64(), nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	preBlockRegex = regexp.MustCompile(`(?is)<pre\b[^>]*>.*?</pre>`)
+	codeTagRegex  = regexp.MustCompile(`(?is)^<pre\b[^>]*>\s*(<code\b[^>]*>)`)
+)
+
+// wrapCodeBlocks puts each <pre> in a container with a copy button, which
+// the layout's script wires to the clipboard, and labels it with the
+// language of its `language-xx` or `lang-xx` class, if any. The label and
+// button text come from CSS so they do not count as words of the post, and
+// the <pre> itself is left byte-for-byte intact for highlighting.
+func wrapCodeBlocks(content string) string {
+	return preBlockRegex.ReplaceAllStringFunc(content, func(block string) string {
+		open := `<div class="code-block">`
+		if lang := codeLanguage(block); lang != "" {
+			open = `<div class="code-block" data-lang="` + html.EscapeString(lang) + `">`
+		}
+		return open + `<button type="button" class="code-copy" data-copy aria-label="Copy code"></button>` + block + `</div>`
+	})
+}
+
+// codeLanguage reads the language from the classes of a <pre> block or the
+// <code> directly inside it.
+func codeLanguage(block string) string {
+	tags := []string{block[:strings.IndexByte(block, '>')+1]}
+	if m := codeTagRegex.FindStringSubmatch(block); m != nil {
+		tags = append(tags, m[1])
+	}
+	for _, tag := range tags {
+		class, _ := getAttr(tag, "class")
+		for _, token := range strings.Fields(html.UnescapeString(class)) {
+			for _, prefix := range []string{"language-", "lang-"} {
+				if lang, ok := strings.CutPrefix(token, prefix); ok && lang != "" {
+					return lang
+				}
+			}
+		}
+	}
+	return ""
+}
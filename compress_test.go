@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompressedPostValidators(t *testing.T) {
+	body := "<p>" + strings.Repeat("A long post body that is worth compressing. ", 100) + "</p>"
+	newTestSite(t, map[string]string{
+		"posts/long.html": testPost("Long", "2024-01-01", body),
+	})
+	saved := pages
+	t.Cleanup(func() { pages = saved })
+	pages = &templateRegistry{}
+	if err := pages.load(); err != nil {
+		t.Fatal(err)
+	}
+	handler := compressHandler(http.HandlerFunc(handlePost))
+	get := func(header http.Header) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/post/long", nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Identity: the strong ETag of the rendered bytes.
+	plain := get(nil)
+	if plain.Code != http.StatusOK || plain.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("identity: status %d, encoding %q", plain.Code, plain.Header().Get("Content-Encoding"))
+	}
+	strong := plain.Header().Get("ETag")
+	if strong == "" || strings.HasPrefix(strong, "W/") {
+		t.Fatalf("identity: ETag %q, want a strong one", strong)
+	}
+
+	// Gzip: the same tag, but weak, since the bytes differ.
+	zipped := get(http.Header{"Accept-Encoding": {"gzip"}})
+	if zipped.Code != http.StatusOK || zipped.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("gzip: status %d, encoding %q", zipped.Code, zipped.Header().Get("Content-Encoding"))
+	}
+	if got := zipped.Header().Get("ETag"); got != "W/"+strong {
+		t.Errorf("gzip: ETag %q, want %q", got, "W/"+strong)
+	}
+	zr, err := gzip.NewReader(zipped.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unzipped, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(unzipped, plain.Body.Bytes()) {
+		t.Error("gzip: body does not decompress to the identity body")
+	}
+
+	// Either validator revalidates to a 304, weak when the client accepts gzip.
+	for _, tt := range []struct {
+		name     string
+		header   http.Header
+		wantETag string
+	}{
+		{"strong, identity", http.Header{"If-None-Match": {strong}}, strong},
+		{"weak, gzip", http.Header{"If-None-Match": {"W/" + strong}, "Accept-Encoding": {"gzip"}}, "W/" + strong},
+		{"strong, gzip", http.Header{"If-None-Match": {strong}, "Accept-Encoding": {"gzip"}}, "W/" + strong},
+	} {
+		rec := get(tt.header)
+		if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+			t.Errorf("%s: status %d with a %d-byte body, want an empty 304", tt.name, rec.Code, rec.Body.Len())
+		}
+		if got := rec.Header().Get("ETag"); got != tt.wantETag {
+			t.Errorf("%s: ETag %q, want %q", tt.name, got, tt.wantETag)
+		}
+	}
+
+	// A range is served from the identity bytes under the strong ETag.
+	part := get(http.Header{"Range": {"bytes=0-99"}, "Accept-Encoding": {"gzip"}})
+	if part.Code != http.StatusPartialContent || part.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("range: status %d, encoding %q; want 206 unencoded", part.Code, part.Header().Get("Content-Encoding"))
+	}
+	if got := part.Header().Get("ETag"); got != strong {
+		t.Errorf("range: ETag %q, want %q", got, strong)
+	}
+	if !bytes.Equal(part.Body.Bytes(), plain.Body.Bytes()[:100]) {
+		t.Errorf("range: body %q is not the first 100 identity bytes", part.Body)
+	}
+
+	// If-Range needs a strong match, so a weak validator gets the whole page.
+	whole := get(http.Header{"Range": {"bytes=0-99"}, "If-Range": {"W/" + strong}})
+	if whole.Code != http.StatusOK || !bytes.Equal(whole.Body.Bytes(), plain.Body.Bytes()) {
+		t.Errorf("range with weak If-Range: status %d, %d bytes; want the full 200", whole.Code, whole.Body.Len())
+	}
+}
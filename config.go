@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SiteConfig holds site-wide settings. Each field is read from an environment
+// variable at startup and falls back to the original hardcoded value, so an
+// unconfigured checkout behaves exactly as before.
+type SiteConfig struct {
+	Title          string         // SITE_TITLE
+	Description    string         // SITE_DESCRIPTION
+	BaseURL        string         // SITE_BASE_URL, default for `build`; scheme and host only
+	BasePath       string         // BASE_PATH, URL prefix when served under a sub-path, e.g. "/blog"
+	Author         string         // SITE_AUTHOR, slug or name credited when a post has none
+	Language       string         // SITE_LANGUAGE, language of posts without a `lang`
+	WordsPerMinute int            // READING_WPM
+	ExcerptLength  int            // EXCERPT_LENGTH, approximate characters in generated excerpts
+	TOCMinHeadings int            // TOC_MIN_HEADINGS, posts with fewer headings get no table of contents
+	HeadingAnchors bool           // HEADING_ANCHORS, add a "#" permalink to each heading; turn off to style your own
+	MaxPinned      int            // MAX_PINNED, validate warns when more posts are pinned
+	PreviewSecret  string         // PREVIEW_SECRET, previews are disabled when empty
+	Location       *time.Location // SITE_TIMEZONE, IANA name applied to bare post dates
+	IndexSort      string         // INDEX_SORT, "published" (default) or "updated"
+	FeedMode       string         // FEED_MODE, "summary" (default) or "full" post content in feeds
+	NoIndex        bool           // NO_INDEX, mark every page noindex, e.g. on staging
+
+	CollectionPageSize int      // COLLECTION_PAGE_SIZE, posts per page of a collection page
+	APIPageSize        int      // API_PAGE_SIZE, posts per page of /api/posts
+	APIAllowedOrigins  []string // API_CORS_ORIGINS, comma-separated origins allowed to call the API, "*" for any
+
+	ExternalLinksNewTab bool   // EXTERNAL_LINKS_NEW_TAB, add target="_blank" to outbound links
+	ExternalLinkClass   string // EXTERNAL_LINK_CLASS, added to outbound links, e.g. "external"
+	ImageCaptions       bool   // IMAGE_CAPTIONS, wrap standalone images in a figure captioned with their alt text
+	CodeCopyButtons     bool   // CODE_COPY_BUTTONS, give code blocks a copy button and language label
+
+	Sanitize           bool     // SANITIZE_HTML, strip scripts, event handlers and unlisted markup from posts
+	SanitizeAllowTags  []string // SANITIZE_ALLOW_TAGS, comma-separated elements to allow beyond the defaults, e.g. "svg,path"
+	SanitizeAllowAttrs []string // SANITIZE_ALLOW_ATTRS, comma-separated attributes to allow on any element, e.g. "style"
+
+	ReadTimeout     time.Duration // SERVER_READ_TIMEOUT, e.g. "10s"
+	WriteTimeout    time.Duration // SERVER_WRITE_TIMEOUT
+	IdleTimeout     time.Duration // SERVER_IDLE_TIMEOUT, for keep-alive connections
+	ShutdownTimeout time.Duration // SHUTDOWN_TIMEOUT, how long in-flight requests may drain
+}
+
+var site = loadSiteConfig()
+
+func loadSiteConfig() SiteConfig {
+	return SiteConfig{
+		Title:          envString("SITE_TITLE", "BreakLab"),
+		Description:    envString("SITE_DESCRIPTION", "Blog posts from BreakLab"),
+		BaseURL:        strings.TrimSuffix(envString("SITE_BASE_URL", "https://example.com"), "/"),
+		BasePath:       normalizeBasePath(os.Getenv("BASE_PATH")),
+		Author:         envString("SITE_AUTHOR", "Brandon"),
+		Language:       envString("SITE_LANGUAGE", "en"),
+		WordsPerMinute: envInt("READING_WPM", 200),
+		ExcerptLength:  envInt("EXCERPT_LENGTH", 200),
+		TOCMinHeadings: envInt("TOC_MIN_HEADINGS", 1),
+		HeadingAnchors: envBool("HEADING_ANCHORS", true),
+		MaxPinned:      envInt("MAX_PINNED", 3),
+		PreviewSecret:  os.Getenv("PREVIEW_SECRET"),
+		Location:       envLocation("SITE_TIMEZONE", time.Local),
+		IndexSort:      envChoice("INDEX_SORT", "published", "updated"),
+		FeedMode:       envChoice("FEED_MODE", "summary", "full"),
+		NoIndex:        envBool("NO_INDEX", false),
+
+		CollectionPageSize: envInt("COLLECTION_PAGE_SIZE", 20),
+		APIPageSize:        envInt("API_PAGE_SIZE", 20),
+		APIAllowedOrigins:  splitList(os.Getenv("API_CORS_ORIGINS")),
+
+		ExternalLinksNewTab: envBool("EXTERNAL_LINKS_NEW_TAB", false),
+		ExternalLinkClass:   os.Getenv("EXTERNAL_LINK_CLASS"),
+		ImageCaptions:       envBool("IMAGE_CAPTIONS", false),
+		CodeCopyButtons:     envBool("CODE_COPY_BUTTONS", true),
+
+		Sanitize:           envBool("SANITIZE_HTML", true),
+		SanitizeAllowTags:  splitList(strings.ToLower(os.Getenv("SANITIZE_ALLOW_TAGS"))),
+		SanitizeAllowAttrs: splitList(strings.ToLower(os.Getenv("SANITIZE_ALLOW_ATTRS"))),
+
+		ReadTimeout:     envDuration("SERVER_READ_TIMEOUT", 10*time.Second),
+		WriteTimeout:    envDuration("SERVER_WRITE_TIMEOUT", 30*time.Second),
+		IdleTimeout:     envDuration("SERVER_IDLE_TIMEOUT", 120*time.Second),
+		ShutdownTimeout: envDuration("SHUTDOWN_TIMEOUT", 10*time.Second),
+	}
+}
+
+func envString(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// normalizeBasePath turns "blog", "/blog/" and "/blog" into "/blog", and ""
+// or "/" into "", so paths can be built as BasePath + "/post/slug".
+func normalizeBasePath(p string) string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return ""
+	}
+	return "/" + p
+}
+
+// envInt reads a positive integer, ignoring unset or malformed values.
+func envInt(key string, fallback int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil && value > 0 {
+		return value
+	}
+	return fallback
+}
+
+// envDuration reads a positive duration such as "30s", ignoring unset or
+// malformed values.
+func envDuration(key string, fallback time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(key)); err == nil && value > 0 {
+		return value
+	}
+	return fallback
+}
+
+// envBool reads a boolean such as "true", "1" or "false", ignoring unset or
+// malformed values.
+func envBool(key string, fallback bool) bool {
+	if value, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return value
+	}
+	return fallback
+}
+
+// envChoice reads one of a fixed set of values; the first is the default.
+// Anything else is reported and ignored.
+func envChoice(key string, choices ...string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return choices[0]
+	}
+	for _, choice := range choices {
+		if value == choice {
+			return value
+		}
+	}
+	fmt.Fprintf(os.Stderr, "ignoring %s: %q is not one of %s\n", key, value, strings.Join(choices, ", "))
+	return choices[0]
+}
+
+// envLocation reads an IANA time zone name such as "Europe/Berlin". An
+// unknown name is reported and ignored rather than silently treated as UTC.
+func envLocation(key string, fallback *time.Location) *time.Location {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	loc, err := time.LoadLocation(value)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ignoring %s: %v\n", key, err)
+		return fallback
+	}
+	return loc
+}
+
+// sitePaths locates the site on disk. Content is the root holding posts/,
+// collections/, authors/, static/ and robots.txt, so the binary can be run
+// from outside the site directory. Set with the -content, -templates and
+// -out flags.
+type sitePaths struct {
+	Content   string
+	Templates string
+	Out       string
+}
+
+var paths = sitePaths{Content: ".", Templates: "templates", Out: "dist"}
+
+// content joins elem onto the content root.
+func (p sitePaths) content(elem ...string) string {
+	return filepath.Join(append([]string{p.Content}, elem...)...)
+}
+
+func (p sitePaths) template(name string) string {
+	return filepath.Join(p.Templates, name)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// exitChangesPending is the exit status of `build -dry-run` when the build
+// would change the output directory, so CI can skip deploys on 0.
+const exitChangesPending = 2
+
+// distChanges lists output files, relative to the output directory, that a
+// build would add, modify or remove.
+type distChanges struct {
+	Added, Modified, Removed []string
+}
+
+func (c distChanges) empty() bool {
+	return len(c.Added)+len(c.Modified)+len(c.Removed) == 0
+}
+
+// print writes one line per changed file, prefixed "+", "~" or "-", and a
+// summary.
+func (c distChanges) print(w io.Writer) {
+	for _, group := range []struct {
+		mark  string
+		files []string
+	}{{"+", c.Added}, {"~", c.Modified}, {"-", c.Removed}} {
+		for _, file := range group.files {
+			fmt.Fprintf(w, "%s %s\n", group.mark, file)
+		}
+	}
+	if c.empty() {
+		fmt.Fprintln(w, "No changes.")
+		return
+	}
+	fmt.Fprintf(w, "%d added, %d modified, %d removed\n", len(c.Added), len(c.Modified), len(c.Removed))
+}
+
+// dryRunBuild builds into a temporary directory and compares the result
+// with opts.OutDir by content hash, leaving opts.OutDir untouched. A missing
+// output directory counts as empty; one the real build would refuse to
+// replace is an error here too.
+func dryRunBuild(opts buildOptions) (distChanges, error) {
+	if err := checkOutDir(opts.OutDir); err != nil {
+		return distChanges{}, err
+	}
+	tmp, err := os.MkdirTemp("", "blog-dry-run-")
+	if err != nil {
+		return distChanges{}, err
+	}
+	defer os.RemoveAll(tmp)
+
+	current := opts.OutDir
+	opts.OutDir, opts.Report = tmp, reportQuiet
+	// Stamp pages with the current build's time, so they only count as
+	// modified when something besides the build date changed.
+	if manifest, err := readManifest(filepath.Join(current, manifestFile)); err == nil {
+		opts.BuiltAt = manifest.BuiltAt
+	}
+	if err := buildStatic(opts); err != nil {
+		return distChanges{}, err
+	}
+
+	before, err := hashTree(current)
+	if err != nil {
+		return distChanges{}, err
+	}
+	after, err := hashTree(tmp)
+	if err != nil {
+		return distChanges{}, err
+	}
+	// The manifest's timestamp differs on every build.
+	delete(before, manifestFile)
+	delete(after, manifestFile)
+
+	var changes distChanges
+	for file, sum := range after {
+		if old, ok := before[file]; !ok {
+			changes.Added = append(changes.Added, file)
+		} else if old != sum {
+			changes.Modified = append(changes.Modified, file)
+		}
+	}
+	for file := range before {
+		if _, ok := after[file]; !ok {
+			changes.Removed = append(changes.Removed, file)
+		}
+	}
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Modified)
+	sort.Strings(changes.Removed)
+	return changes, nil
+}
+
+// hashTree returns the SHA-256 of every file under dir, keyed by its
+// slash-separated path relative to dir.
+func hashTree(dir string) (map[string][sha256.Size]byte, error) {
+	sums := make(map[string][sha256.Size]byte)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		sums[filepath.ToSlash(rel)] = sha256.Sum256(content)
+		return nil
+	})
+	return sums, err
+}
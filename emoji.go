@@ -0,0 +1,122 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// emojiShortcodes maps the `:name:` shortcodes expanded in posts to their
+// emoji. The names follow GitHub's, so drafts read the same there.
+var emojiShortcodes = map[string]string{
+	"+1":                       "👍",
+	"-1":                       "👎",
+	"100":                      "💯",
+	"bangbang":                 "‼️",
+	"beers":                    "🍻",
+	"bell":                     "🔔",
+	"book":                     "📖",
+	"books":                    "📚",
+	"boom":                     "💥",
+	"brain":                    "🧠",
+	"bug":                      "🐛",
+	"bulb":                     "💡",
+	"calendar":                 "📆",
+	"chart_with_upwards_trend": "📈",
+	"check":                    "✔️",
+	"clap":                     "👏",
+	"coffee":                   "☕",
+	"computer":                 "💻",
+	"confused":                 "😕",
+	"construction":             "🚧",
+	"cry":                      "😢",
+	"eyes":                     "👀",
+	"fire":                     "🔥",
+	"gear":                     "⚙️",
+	"grin":                     "😁",
+	"heart":                    "❤️",
+	"hourglass":                "⌛",
+	"joy":                      "😂",
+	"key":                      "🔑",
+	"laughing":                 "😆",
+	"link":                     "🔗",
+	"lock":                     "🔒",
+	"mag":                      "🔍",
+	"memo":                     "📝",
+	"muscle":                   "💪",
+	"no_entry":                 "⛔",
+	"ok_hand":                  "👌",
+	"package":                  "📦",
+	"pencil2":                  "✏️",
+	"point_right":              "👉",
+	"pray":                     "🙏",
+	"pushpin":                  "📌",
+	"question":                 "❓",
+	"rocket":                   "🚀",
+	"robot":                    "🤖",
+	"see_no_evil":              "🙈",
+	"smile":                    "😄",
+	"smiley":                   "😃",
+	"sparkles":                 "✨",
+	"star":                     "⭐",
+	"sweat_smile":              "😅",
+	"tada":                     "🎉",
+	"thinking":                 "🤔",
+	"thumbsdown":               "👎",
+	"thumbsup":                 "👍",
+	"trophy":                   "🏆",
+	"warning":                  "⚠️",
+	"wave":                     "👋",
+	"white_check_mark":         "✅",
+	"wink":                     "😉",
+	"wrench":                   "🔧",
+	"x":                        "❌",
+	"zap":                      "⚡",
+}
+
+var (
+	shortcodeRegex = regexp.MustCompile(`:([a-z0-9_+-]+):`)
+	tagRegex       = regexp.MustCompile(`<[^>]*>`)
+)
+
+// expandEmoji replaces known shortcodes in the text of content with their
+// emoji. Code blocks and tags, including attribute values such as URLs,
+// are left alone, and so are unknown shortcodes, such as the ":30:" in
+// "10:30:00".
+func expandEmoji(content string) string {
+	if !strings.Contains(content, ":") {
+		return content
+	}
+	return transformOutsideCode(content, func(s string) string {
+		var b strings.Builder
+		last := 0
+		for _, loc := range tagRegex.FindAllStringIndex(s, -1) {
+			b.WriteString(expandShortcodes(s[last:loc[0]]))
+			b.WriteString(s[loc[0]:loc[1]])
+			last = loc[1]
+		}
+		b.WriteString(expandShortcodes(s[last:]))
+		return b.String()
+	})
+}
+
+// expandShortcodes scans s itself rather than using ReplaceAllStringFunc so
+// that an unknown code gives its closing colon back: in ":foo:rocket:" the
+// rocket still expands.
+func expandShortcodes(s string) string {
+	var b strings.Builder
+	for {
+		loc := shortcodeRegex.FindStringSubmatchIndex(s)
+		if loc == nil {
+			b.WriteString(s)
+			return b.String()
+		}
+		if emoji, ok := emojiShortcodes[s[loc[2]:loc[3]]]; ok {
+			b.WriteString(s[:loc[0]])
+			b.WriteString(emoji)
+			s = s[loc[1]:]
+		} else {
+			b.WriteString(s[:loc[1]-1])
+			s = s[loc[1]-1:]
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEmojiShortcodesTable(t *testing.T) {
+	for name, emoji := range emojiShortcodes {
+		code := ":" + name + ":"
+		if m := shortcodeRegex.FindStringSubmatch(code); m == nil || m[1] != name {
+			t.Errorf("%s: the shortcode pattern does not match the name", code)
+			continue
+		}
+		if emoji == "" || strings.ContainsAny(emoji, ":<>&") {
+			t.Errorf("%s: bad emoji %q", code, emoji)
+		}
+		if got, want := expandEmoji("a "+code+" b"), "a "+emoji+" b"; got != want {
+			t.Errorf("expandEmoji(%q) = %q, want %q", "a "+code+" b", got, want)
+		}
+	}
+}
+
+func TestExpandEmoji(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"known", "Shipped :rocket: today", "Shipped 🚀 today"},
+		{"several", ":+1::-1: :tada:", "👍👎 🎉"},
+		{"aliases", ":thumbsup: :+1:", "👍 👍"},
+		{"no colon", "plain text", "plain text"},
+		{"unknown", "a :not_an_emoji: b", "a :not_an_emoji: b"},
+		{"time of day", "at 10:30:00", "at 10:30:00"},
+		{"unknown gives back its colon", ":foo:rocket:", ":foo🚀"},
+		{"uppercase is not a shortcode", ":Rocket:", ":Rocket:"},
+		{"inside code", "<p>:fire:</p><pre><code>:fire:</code></pre>", "<p>🔥</p><pre><code>:fire:</code></pre>"},
+		{"inline code", "<code>:fire:</code> :fire:", "<code>:fire:</code> 🔥"},
+		{"attribute values", `<a href="/x:link:y" title=":link:">:link:</a>`, `<a href="/x:link:y" title=":link:">🔗</a>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expandEmoji(tt.in); got != tt.want {
+				t.Errorf("expandEmoji(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// renderedFeed is an encoded feed document with its validators.
+type renderedFeed struct {
+	body     []byte
+	etag     string
+	modified time.Time
+}
+
+// feedCache keeps encoded feeds between polls. It is tied to the post
+// cache: when contentCache reloads, every cached feed is dropped.
+type feedCache struct {
+	mu    sync.Mutex
+	stamp uint64
+	feeds map[string]renderedFeed
+}
+
+var feedsCache = &feedCache{}
+
+// get returns the feed cached under key for the content at stamp, calling
+// render only when there is none.
+func (c *feedCache) get(stamp uint64, key string, render func() (renderedFeed, error)) (renderedFeed, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.feeds == nil || c.stamp != stamp {
+		c.feeds, c.stamp = make(map[string]renderedFeed), stamp
+	}
+	if feed, ok := c.feeds[key]; ok {
+		return feed, nil
+	}
+	feed, err := render()
+	if err != nil {
+		return renderedFeed{}, err
+	}
+	c.feeds[key] = feed
+	return feed, nil
+}
+
+func encodeRSS(feed RSS, modified time.Time) (renderedFeed, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&buf)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(feed); err != nil {
+		return renderedFeed{}, err
+	}
+	return renderedFeed{body: buf.Bytes(), etag: contentETag(buf.Bytes()), modified: modified}, nil
+}
+
+// contentETag is a strong ETag for a response body.
+func contentETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// newestUpdate is the latest publish or revision date among posts, the
+// Last-Modified of a feed listing them.
+func newestUpdate(posts []Post) time.Time {
+	var newest time.Time
+	for _, post := range posts {
+		if t := lastUpdated(post); t.After(newest) {
+			newest = t
+		}
+	}
+	return newest
+}
+
+// serveRSS answers a feed request for posts, building the feed with newFeed
+// only when it is not cached. The response carries an ETag and a
+// Last-Modified, so a poller that already has the current feed gets a 304
+// without a body.
+func serveRSS(w http.ResponseWriter, r *http.Request, posts []Post, newFeed func() RSS) {
+	// The published set is part of the key because scheduled posts appear
+	// without any file changing.
+	key := fmt.Sprintf("%s\x00%s\x00%d", requestBaseURL(r), r.URL.Path, len(posts))
+	feed, err := feedsCache.get(postCache.version(), key, func() (renderedFeed, error) {
+		return encodeRSS(newFeed(), newestUpdate(posts))
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	w.Header().Set("ETag", feed.etag)
+	http.ServeContent(w, r, "", feed.modified, bytes.NewReader(feed.body))
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func fetchFeed(t *testing.T, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/feed.xml", nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	handleRSS(rec, req)
+	return rec
+}
+
+func TestFeedConditionalGet(t *testing.T) {
+	dir := newTestSite(t, map[string]string{
+		"posts/first.html": testPost("First", "2024-01-01", "<p>One.</p>"),
+	})
+
+	// First fetch: the whole feed with its validators.
+	first := fetchFeed(t, nil)
+	if first.Code != http.StatusOK {
+		t.Fatalf("first fetch: status %d, want 200", first.Code)
+	}
+	etag := first.Header().Get("ETag")
+	modified := first.Header().Get("Last-Modified")
+	if etag == "" || modified == "" {
+		t.Fatalf("first fetch: ETag %q, Last-Modified %q; want both", etag, modified)
+	}
+	if ct := first.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/rss+xml") {
+		t.Errorf("first fetch: Content-Type %q", ct)
+	}
+	if !strings.Contains(first.Body.String(), "<title>First</title>") {
+		t.Errorf("first fetch: body lacks the post:\n%s", first.Body)
+	}
+
+	// Matching validators: 304 with no body.
+	for name, header := range map[string]http.Header{
+		"If-None-Match":     {"If-None-Match": {etag}},
+		"If-Modified-Since": {"If-Modified-Since": {modified}},
+	} {
+		rec := fetchFeed(t, header)
+		if rec.Code != http.StatusNotModified {
+			t.Errorf("%s: status %d, want 304", name, rec.Code)
+		}
+		if rec.Body.Len() != 0 {
+			t.Errorf("%s: 304 carried a %d-byte body", name, rec.Body.Len())
+		}
+	}
+
+	// A new post: the same validators now get the new feed.
+	writeTestFile(t, filepath.Join(dir, "posts", "second.html"), testPost("Second", "2024-02-01", "<p>Two.</p>"))
+	after := fetchFeed(t, http.Header{"If-None-Match": {etag}})
+	if after.Code != http.StatusOK {
+		t.Fatalf("after new post: status %d, want 200", after.Code)
+	}
+	if newETag := after.Header().Get("ETag"); newETag == "" || newETag == etag {
+		t.Errorf("after new post: ETag %q, want a new one (was %q)", newETag, etag)
+	}
+	if !strings.Contains(after.Body.String(), "<title>Second</title>") {
+		t.Errorf("after new post: body lacks the new post:\n%s", after.Body)
+	}
+}
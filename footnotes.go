@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	footnoteDefRegex = regexp.MustCompile(`(?m)^[ \t]*\[\^([^\]\s]+)\]:[ \t]*(.*)$`)
+	footnoteRefRegex = regexp.MustCompile(`\[\^([^\]\s]+)\]`)
+)
+
+// processFootnotes rewrites "[^id]" references into numbered superscript
+// links and moves the matching "[^id]: text" definition lines into a notes
+// section at the end of the content, each with a link back to its first
+// reference. Notes are numbered in order of first reference; definitions
+// that are never referenced are dropped and returned by name so the caller
+// can warn about them. IDs are prefixed with scope (the post slug) so notes
+// from two posts rendered on one page cannot collide, and are made unique
+// against every id already in the content, such as heading anchors. Content
+// without footnote definitions is returned unchanged.
+func processFootnotes(content, scope string) (string, []string) {
+	definitions := make(map[string]string)
+	content = transformOutsideCode(content, func(s string) string {
+		return footnoteDefRegex.ReplaceAllStringFunc(s, func(match string) string {
+			m := footnoteDefRegex.FindStringSubmatch(match)
+			definitions[m[1]] = strings.TrimSpace(m[2])
+			return ""
+		})
+	})
+	if len(definitions) == 0 {
+		return content, nil
+	}
+
+	taken := make(map[string]bool)
+	for _, m := range elementIDRegex.FindAllStringSubmatch(content, -1) {
+		taken[m[1]] = true
+	}
+	ids := make(map[string][2]string) // name -> {note id, reference id}
+	for name := range definitions {
+		ids[name] = [2]string{uniqueID(footnoteID("fn", scope, name), taken), uniqueID(footnoteID("fnref", scope, name), taken)}
+	}
+
+	var order []string
+	numbers := make(map[string]int)
+	content = transformOutsideCode(content, func(s string) string {
+		return footnoteRefRegex.ReplaceAllStringFunc(s, func(match string) string {
+			name := footnoteRefRegex.FindStringSubmatch(match)[1]
+			if _, ok := definitions[name]; !ok {
+				return match
+			}
+			refID := ""
+			if _, seen := numbers[name]; !seen {
+				order = append(order, name)
+				numbers[name] = len(order)
+				refID = fmt.Sprintf(` id="%s"`, ids[name][1])
+			}
+			return fmt.Sprintf(`<sup class="footnote-ref"%s><a href="#%s">%d</a></sup>`,
+				refID, ids[name][0], numbers[name])
+		})
+	})
+
+	var unused []string
+	for name := range definitions {
+		if _, ok := numbers[name]; !ok {
+			unused = append(unused, name)
+		}
+	}
+	sort.Strings(unused)
+	if len(order) == 0 {
+		return content, unused
+	}
+
+	var b strings.Builder
+	b.WriteString(content)
+	b.WriteString("\n<section class=\"post-footnotes\">\n")
+	for _, name := range order {
+		fmt.Fprintf(&b, `<div class="footnote" id="%s"><span class="footnote-number">%d</span>%s<a href="#%s" class="footnote-backlink">↩</a></div>`+"\n",
+			ids[name][0], numbers[name], definitions[name], ids[name][1])
+	}
+	b.WriteString("</section>\n")
+	return b.String(), unused
+}
+
+func footnoteID(prefix, scope, name string) string {
+	return prefix + "-" + generateID(scope+"-"+name)
+}
+
+// uniqueID returns id, or id with a numeric suffix if it is already taken,
+// and marks the result as taken.
+func uniqueID(id string, taken map[string]bool) string {
+	candidate := id
+	for i := 2; taken[candidate]; i++ {
+		candidate = fmt.Sprintf("%s-%d", id, i)
+	}
+	taken[candidate] = true
+	return candidate
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+)
+
+// Theme helpers registered in templateFuncs. They take loosely typed
+// arguments so templates can pass whatever field they have at hand, and
+// render an empty string instead of failing the page when given something
+// they cannot use:
+//
+//	{{dateFormat "Jan 2006" .RawDate}}        date from RawDate, RFC 3339 or a time.Time
+//	{{truncateWords 30 .Content}}             first 30 words as plain text, "…" if cut
+//	{{slugify "Hello, World"}}                "hello-world", like heading ids
+//	{{absURL "/tag/go"}}                      "https://example.com/tag/go"
+//	{{pluralize .Count "post" "posts"}}       singular only when the count is 1
+//	{{jsonify .}}                             JSON, safe inside <script>
+//	{{relativeTime .RawDate}}                 "yesterday", "3 weeks ago", ...
+
+// now is the clock relativeTime and post scheduling measure against. It is a
+// variable so tests can pin it to a fixed time.
+var now = time.Now
+
+// templateText returns the text of a string-like template argument.
+func templateText(v any) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case template.HTML:
+		return string(v), true
+	}
+	return "", false
+}
+
+// templateInt returns the value of an integer template argument.
+func templateInt(v any) (int, bool) {
+	switch v := v.(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case uint:
+		return int(v), true
+	}
+	return 0, false
+}
+
+// dateFormat formats a post date with a Go layout in the site timezone.
+func dateFormat(layout string, date any) string {
+	var t time.Time
+	switch v := date.(type) {
+	case time.Time:
+		t = v
+	case string:
+		if v == "" {
+			return ""
+		}
+		parsed, err := parsePostDate(v)
+		if err != nil {
+			return ""
+		}
+		t = parsed
+	default:
+		return ""
+	}
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(site.Location).Format(layout)
+}
+
+// truncateWords strips markup from s and keeps its first n words. The
+// result is plain text, so html/template escapes it wherever it lands.
+func truncateWords(n any, s any) string {
+	limit, ok := templateInt(n)
+	text, textOK := templateText(s)
+	if !ok || !textOK || limit < 0 {
+		return ""
+	}
+	words := strings.Fields(stripHTML(text))
+	if len(words) <= limit {
+		return strings.Join(words, " ")
+	}
+	return strings.Join(words[:limit], " ") + "…"
+}
+
+func slugify(s any) string {
+	text, ok := templateText(s)
+	if !ok {
+		return ""
+	}
+	return generateID(text)
+}
+
+// absURL turns a site path into an absolute URL on SITE_BASE_URL, adding
+// the base path. Absolute URLs pass through unchanged.
+func absURL(p any) string {
+	text, ok := templateText(p)
+	if !ok {
+		return ""
+	}
+	if !strings.Contains(text, "://") && !strings.HasPrefix(text, "/") {
+		text = "/" + text
+	}
+	return absoluteURL(site.BaseURL, text)
+}
+
+func pluralize(n any, singular, plural string) string {
+	count, ok := templateInt(n)
+	if !ok {
+		return ""
+	}
+	if count == 1 {
+		return singular
+	}
+	return plural
+}
+
+// jsonify encodes v for embedding in a <script> block. encoding/json
+// escapes <, > and &, so the output cannot close the element early.
+func jsonify(v any) template.JS {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return template.JS(data)
+}
+
+// relativeTime describes a post date relative to today in the site
+// timezone, counting calendar days. A date that does not parse is returned
+// as written.
+func relativeTime(rawDate any) string {
+	raw, ok := templateText(rawDate)
+	if !ok || raw == "" {
+		return ""
+	}
+	t, err := parsePostDate(raw)
+	if err != nil {
+		return raw
+	}
+	y, m, d := t.In(site.Location).Date()
+	ty, tm, td := now().In(site.Location).Date()
+	// Dates at UTC midnight count whole days regardless of DST changes.
+	days := int(time.Date(ty, tm, td, 0, 0, 0, 0, time.UTC).Sub(time.Date(y, m, d, 0, 0, 0, 0, time.UTC)).Hours() / 24)
+
+	switch {
+	case days < 0:
+		return "upcoming"
+	case days == 0:
+		return "today"
+	case days == 1:
+		return "yesterday"
+	case days < 7:
+		return fmt.Sprintf("%d days ago", days)
+	case days < 30:
+		return countAgo(days/7, "week")
+	case days < 365:
+		// 360-364 days would be "12 months"; those still read as 11.
+		return countAgo(min(11, max(1, days/30)), "month")
+	case days < 730:
+		return "over a year ago"
+	}
+	return fmt.Sprintf("over %d years ago", days/365)
+}
+
+func countAgo(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit + " ago"
+	}
+	return fmt.Sprintf("%d %ss ago", n, unit)
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withSite restores the site config when the test ends.
+func withSite(t *testing.T) {
+	saved := site
+	t.Cleanup(func() { site = saved })
+}
+
+// withNow pins the clock to at until the test ends.
+func withNow(t *testing.T, at time.Time) {
+	saved := now
+	t.Cleanup(func() { now = saved })
+	now = func() time.Time { return at }
+}
+
+func TestDateFormat(t *testing.T) {
+	withSite(t)
+	site.Location = time.FixedZone("UTC+2", 2*60*60)
+	tests := []struct {
+		layout string
+		date   any
+		want   string
+	}{
+		{"Jan 2006", "2024-03-05", "Mar 2024"},
+		{"2006-01-02 15:04", "2024-03-05T23:30:00Z", "2024-03-06 01:30"},
+		{"January 2", time.Date(2024, 12, 25, 12, 0, 0, 0, time.UTC), "December 25"},
+		{"2006", time.Time{}, ""},
+		{"2006", "", ""},
+		{"2006", "not a date", ""},
+		{"2006", 2024, ""},
+		{"2006", nil, ""},
+	}
+	for _, tt := range tests {
+		if got := dateFormat(tt.layout, tt.date); got != tt.want {
+			t.Errorf("dateFormat(%q, %#v) = %q, want %q", tt.layout, tt.date, got, tt.want)
+		}
+	}
+}
+
+func TestTruncateWords(t *testing.T) {
+	tests := []struct {
+		n    any
+		s    any
+		want string
+	}{
+		{3, "one two three four", "one two three…"},
+		{4, "one two three four", "one two three four"},
+		{10, "short", "short"},
+		{2, template.HTML("<p>one <em>two</em></p><p>three &amp; four</p>"), "one two…"},
+		{3, template.HTML("<p>a</p><script>x y z</script><p>b &lt;c&gt;</p>"), "a b <c>"},
+		{-1, "one", ""},
+		{"3", "one two three four", ""},
+		{3, 42, ""},
+	}
+	for _, tt := range tests {
+		if got := truncateWords(tt.n, tt.s); got != tt.want {
+			t.Errorf("truncateWords(%#v, %#v) = %q, want %q", tt.n, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		in   any
+		want string
+	}{
+		{"Hello, World", "hello-world"},
+		{template.HTML("<code>Go</code> Tips"), "go-tips"},
+		{"Über Caching", "über-caching"},
+		{42, ""},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := slugify(tt.in); got != tt.want {
+			t.Errorf("slugify(%#v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestAbsURL(t *testing.T) {
+	withSite(t)
+	site.BaseURL = "https://example.com/"
+	site.BasePath = "/blog"
+	tests := []struct {
+		in   any
+		want string
+	}{
+		{"/tag/go", "https://example.com/blog/tag/go"},
+		{"post/a", "https://example.com/blog/post/a"},
+		{"https://other.example/x", "https://other.example/x"},
+		{42, ""},
+	}
+	for _, tt := range tests {
+		if got := absURL(tt.in); got != tt.want {
+			t.Errorf("absURL(%#v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPluralize(t *testing.T) {
+	tests := []struct {
+		n    any
+		want string
+	}{
+		{0, "posts"},
+		{1, "post"},
+		{2, "posts"},
+		{int64(1), "post"},
+		{uint(3), "posts"},
+		{"1", ""},
+		{1.0, ""},
+	}
+	for _, tt := range tests {
+		if got := pluralize(tt.n, "post", "posts"); got != tt.want {
+			t.Errorf("pluralize(%#v) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestJsonify(t *testing.T) {
+	in := map[string]any{"title": "</script><script>alert(1)</script>", "tags": []string{"a & b"}, "n": 3}
+	got := string(jsonify(in))
+	if strings.ContainsAny(got, "<>&") {
+		t.Errorf("jsonify output %s contains unescaped <, > or &", got)
+	}
+	var back map[string]any
+	if err := json.Unmarshal([]byte(got), &back); err != nil {
+		t.Fatalf("jsonify output %s is not JSON: %v", got, err)
+	}
+	if back["title"] != in["title"] || back["n"] != 3.0 {
+		t.Errorf("jsonify round trip = %v, want %v", back, in)
+	}
+	if got := jsonify(make(chan int)); got != "" {
+		t.Errorf("jsonify(chan) = %q, want empty", got)
+	}
+}
+
+func TestRelativeTime(t *testing.T) {
+	withSite(t)
+	site.Location = time.UTC
+	withNow(t, time.Date(2024, 6, 15, 9, 0, 0, 0, time.UTC))
+	tests := []struct {
+		in   any
+		want string
+	}{
+		{"2024-06-15", "today"},
+		{"2024-06-15T23:59:59Z", "today"},
+		{"2024-06-16", "upcoming"},
+		{"2024-06-14", "yesterday"},
+		{"2024-06-14T23:59:59Z", "yesterday"},
+		{"2024-06-12", "3 days ago"},
+		{"2024-06-09", "6 days ago"},
+		{"2024-06-08", "1 week ago"},
+		{"2024-05-19", "3 weeks ago"},
+		{"2024-05-16", "1 month ago"},
+		{"2024-04-15", "2 months ago"},
+		{"2023-07-20", "11 months ago"},
+		{"2023-06-21", "11 months ago"}, // 360 days
+		{"2023-06-17", "11 months ago"}, // 364 days
+		{"2023-06-15", "over a year ago"},
+		{"2021-06-15", "over 3 years ago"},
+		{"someday", "someday"},
+		{"", ""},
+		{42, ""},
+	}
+	for _, tt := range tests {
+		if got := relativeTime(tt.in); got != tt.want {
+			t.Errorf("relativeTime(%#v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRelativeTimeUsesSiteTimezone(t *testing.T) {
+	withSite(t)
+	// 23:30 UTC on the 14th is already the 15th here, so a post from the
+	// 14th is yesterday, not today.
+	site.Location = time.FixedZone("UTC+2", 2*60*60)
+	withNow(t, time.Date(2024, 6, 14, 23, 30, 0, 0, time.UTC))
+	if got := relativeTime("2024-06-14"); got != "yesterday" {
+		t.Errorf("relativeTime = %q, want yesterday", got)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"html"
+	"strings"
+)
+
+// rawTextElements hold content that is never shown as text.
+var rawTextElements = map[string]bool{"script": true, "style": true, "noscript": true, "template": true}
+
+// blockElements start a new line of text, so adjacent blocks such as
+// "<p>end.</p><p>Start" do not run their words together.
+var blockElements = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "br": true,
+	"dd": true, "div": true, "dl": true, "dt": true, "figcaption": true, "figure": true,
+	"footer": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"header": true, "hr": true, "li": true, "main": true, "nav": true, "ol": true,
+	"p": true, "pre": true, "section": true, "table": true, "td": true, "th": true,
+	"tr": true, "ul": true,
+}
+
+// stripHTML converts an HTML fragment to plain text: tags are removed, the
+// contents of script, style and similar elements are dropped, block
+// boundaries become whitespace, entities are decoded and runs of whitespace
+// collapse to single spaces. Malformed markup degrades to text rather than
+// swallowing the rest of the input.
+func stripHTML(s string) string {
+	var b strings.Builder
+	for len(s) > 0 {
+		lt := strings.IndexByte(s, '<')
+		if lt < 0 {
+			b.WriteString(html.UnescapeString(s))
+			break
+		}
+		b.WriteString(html.UnescapeString(s[:lt]))
+		s = s[lt:]
+
+		name, end, ok := scanTag(s)
+		if !ok {
+			// A stray "<" that does not open a tag is literal text.
+			b.WriteByte('<')
+			s = s[1:]
+			continue
+		}
+		s = s[end:]
+		closing := strings.HasPrefix(name, "/")
+		name = strings.TrimPrefix(name, "/")
+		if blockElements[name] {
+			b.WriteByte('\n')
+		}
+		if !closing && rawTextElements[name] {
+			s = skipRawText(s, name)
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// transformTextNodes applies fn to the text between tags in s. Tags,
+// comments and the contents of script, style and similar elements, where
+// text is not shown as such, are copied unchanged; this includes attribute
+// values, so fn never sees a quote it could break.
+func transformTextNodes(s string, fn func(string) string) string {
+	var b strings.Builder
+	text := 0
+	for i := 0; i < len(s); {
+		if s[i] != '<' {
+			i++
+			continue
+		}
+		name, end, ok := scanTag(s[i:])
+		if !ok {
+			i++
+			continue
+		}
+		b.WriteString(fn(s[text:i]))
+		next := i + end
+		if rawTextElements[name] {
+			next = len(s) - len(skipRawText(s[next:], name))
+		}
+		b.WriteString(s[i:next])
+		i, text = next, next
+	}
+	b.WriteString(fn(s[text:]))
+	return b.String()
+}
+
+// scanTag reads the tag, comment or declaration at the start of s and returns
+// its lower-cased name ("/p" for end tags, "" for comments and declarations)
+// and its length. ok is false when s does not start a tag. A tag left open at
+// the end of the input consumes the rest of it.
+func scanTag(s string) (name string, end int, ok bool) {
+	if len(s) < 2 {
+		return "", 0, false
+	}
+	switch c := s[1]; {
+	case strings.HasPrefix(s, "<!--"):
+		return "", commentEnd(s), true
+	case c == '!' || c == '?':
+		if i := strings.IndexByte(s, '>'); i >= 0 {
+			return "", i + 1, true
+		}
+		return "", len(s), true
+	case c == '/' || isASCIILetter(c):
+	default:
+		return "", 0, false
+	}
+
+	i := 1
+	if s[i] == '/' {
+		i++
+	}
+	start := i
+	for i < len(s) && !isTagNameEnd(s[i]) {
+		i++
+	}
+	name = strings.ToLower(s[start:i])
+	if s[1] == '/' {
+		if name == "" {
+			return "", 0, false
+		}
+		name = "/" + name
+	}
+
+	// Skip attributes, honouring quotes so a ">" inside a value does not end
+	// the tag.
+	var quote byte
+	for ; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return name, i + 1, true
+		}
+	}
+	return name, len(s), true
+}
+
+// commentEnd returns the length of the comment at the start of s, ending
+// it where browsers do: "<!-->" and "<!--->" are empty comments, and
+// otherwise the first "-->" or "--!>" closes it. An unclosed comment runs to
+// the end of the input.
+func commentEnd(s string) int {
+	for _, empty := range []string{"<!-->", "<!--->"} {
+		if strings.HasPrefix(s, empty) {
+			return len(empty)
+		}
+	}
+	end := len(s)
+	for _, closer := range []string{"-->", "--!>"} {
+		if i := strings.Index(s[4:], closer); i >= 0 {
+			end = min(end, 4+i+len(closer))
+		}
+	}
+	return end
+}
+
+// skipRawText returns s after the end tag of the raw text element name, or
+// "" when the element is never closed.
+func skipRawText(s, name string) string {
+	lower := strings.ToLower(s)
+	for offset := 0; ; {
+		i := strings.Index(lower[offset:], "</"+name)
+		if i < 0 {
+			return ""
+		}
+		i += offset
+		if j := i + 2 + len(name); j == len(s) || isTagNameEnd(s[j]) {
+			_, end, _ := scanTag(s[i:])
+			return s[i+end:]
+		}
+		offset = i + 2
+	}
+}
+
+func isTagNameEnd(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '/' || c == '>'
+}
+
+func isASCIILetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
@@ -0,0 +1,78 @@
+package main
+
+import "testing"
+
+func TestStripHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "just text", "just text"},
+		{"entities", "Fish &amp; chips &lt;3 &quot;caf&eacute;&quot; &#8212; &#x41;", `Fish & chips <3 "café" — A`},
+		{"double-escaped entity stays escaped once", "&amp;amp;", "&amp;"},
+		{"nested tags", "<div><p>One <em>two <strong>three</strong></em></p></div>", "One two three"},
+		{"block boundaries", "<p>end.</p><p>Start</p><ul><li>a</li><li>b</li></ul>x<br>y", "end. Start a b x y"},
+		{"inline boundaries do not split words", "un<em>believ</em>able", "unbelievable"},
+		{"script and style dropped", "a<script>var x = '<p>';</script>b<style>p { color: red }</style>c", "abc"},
+		{"raw text end tag needs a boundary", "a<script>x</scripts>y</script>b", "ab"},
+		{"unclosed script drops the rest", "a<script>alert(1)", "a"},
+		{"attribute with >", `<a title="a > b" href="/x">link</a>`, "link"},
+		{"whitespace collapses", "  a \n\t b  ", "a b"},
+
+		// Malformed markup degrades to text rather than eating the input.
+		{"stray <", "1 < 2 and 3 <4", "1 < 2 and 3 <4"},
+		{"lone < at end", "a <", "a <"},
+		{"empty end tag", "a </> b", "a </> b"},
+		{"unclosed tag at end", "a <p class=", "a"},
+		{"unclosed quote", `a <a href="x>b`, "a"},
+
+		// Comments end where browsers end them.
+		{"comment", "a<!-- hidden -->b", "ab"},
+		{"empty comment", "a<!---->b", "ab"},
+		{"abrupt comment", "a<!-->b", "ab"},
+		{"abrupt comment with dash", "a<!--->b", "ab"},
+		{"bang-closed comment", "a<!-- x --!>b", "ab"},
+		{"comment with > inside", "a<!-- x > y -->b", "ab"},
+		{"unclosed comment", "a<!-- never closed", "a"},
+		{"declaration", "<!DOCTYPE html>a<?xml version='1.0'?>b", "ab"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stripHTML(tt.in); got != tt.want {
+				t.Errorf("stripHTML(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanTag(t *testing.T) {
+	tests := []struct {
+		in   string
+		name string
+		end  int
+		ok   bool
+	}{
+		{"<p>x", "p", 3, true},
+		{"<P CLASS='a'>", "p", 13, true},
+		{"</Div >x", "/div", 7, true},
+		{`<a href="x>y">z`, "a", 14, true},
+		{"<br/>", "br", 5, true},
+		{"<!-- c -->x", "", 10, true},
+		{"<!-->x", "", 5, true},
+		{"<!--->x", "", 6, true},
+		{"<!-- a --!>x", "", 11, true},
+		{"<!-- a", "", 6, true},
+		{"<!doctype html>x", "", 15, true},
+		{"< p>", "", 0, false},
+		{"<3", "", 0, false},
+		{"</>", "", 0, false},
+		{"<", "", 0, false},
+	}
+	for _, tt := range tests {
+		name, end, ok := scanTag(tt.in)
+		if name != tt.name || end != tt.end || ok != tt.ok {
+			t.Errorf("scanTag(%q) = %q, %d, %v; want %q, %d, %v", tt.in, name, end, ok, tt.name, tt.end, tt.ok)
+		}
+	}
+}
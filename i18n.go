@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+// Translation links a post to a version of it in another language.
+type Translation struct {
+	Slug  string
+	Lang  string
+	Name  string // the language's name in itself, e.g. "Türkçe"
+	Title string
+	URL   string // absolute, filled in when the page is rendered
+}
+
+// languageName returns a language's own name for itself, falling back to
+// the code for tags the display tables do not know.
+func languageName(lang string) string {
+	if name := display.Self.Name(language.Make(lang)); name != "" {
+		return name
+	}
+	return lang
+}
+
+// translationGroup is the slug shared by a post and all of its translations:
+// the slug of the original.
+func translationGroup(post Post) string {
+	if post.TranslationOf != "" {
+		return post.TranslationOf
+	}
+	return post.Slug
+}
+
+// translationsFor lists the published posts in post's translation group,
+// other than post itself, ordered by language.
+func translationsFor(post Post, all []Post) []Translation {
+	var translations []Translation
+	group := translationGroup(post)
+	for _, other := range all {
+		if other.Slug == post.Slug || translationGroup(other) != group || !isPublished(other) {
+			continue
+		}
+		translations = append(translations, Translation{Slug: other.Slug, Lang: other.Lang, Name: languageName(other.Lang), Title: other.Title})
+	}
+	sort.Slice(translations, func(i, j int) bool {
+		return translations[i].Lang < translations[j].Lang
+	})
+	return translations
+}
+
+// withTranslationURLs returns a copy of translations with absolute URLs, so
+// cached posts are never modified per request.
+func withTranslationURLs(translations []Translation, baseURL string) []Translation {
+	result := make([]Translation, len(translations))
+	for i, t := range translations {
+		t.URL = canonicalURL(baseURL, "/post/"+t.Slug)
+		result[i] = t
+	}
+	return result
+}
+
+// inLanguage returns the posts written in lang, preserving order.
+func inLanguage(posts []Post, lang string) []Post {
+	var result []Post
+	for _, post := range posts {
+		if post.Lang == lang {
+			result = append(result, post)
+		}
+	}
+	return result
+}
+
+// otherLanguages lists the languages other than the site's that posts are
+// written in. Each gets its own index page at /<lang>/.
+func otherLanguages(posts []Post) []string {
+	seen := make(map[string]bool)
+	var langs []string
+	for _, post := range posts {
+		if post.Lang != site.Language && !seen[post.Lang] {
+			seen[post.Lang] = true
+			langs = append(langs, post.Lang)
+		}
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// validateTranslations reports translation-of links that point at a
+// missing post, at a post in the same language, or at another translation
+// rather than the original.
+func validateTranslations(posts []Post) error {
+	bySlug := make(map[string]Post, len(posts))
+	for _, post := range posts {
+		bySlug[post.Slug] = post
+	}
+	var errs []error
+	for _, post := range posts {
+		if post.TranslationOf == "" {
+			continue
+		}
+		original, ok := bySlug[post.TranslationOf]
+		switch {
+		case !ok:
+			errs = append(errs, fmt.Errorf("post %q is a translation of unknown post %q", post.Slug, post.TranslationOf))
+		case original.Lang == post.Lang:
+			errs = append(errs, fmt.Errorf("post %q is a translation of %q but both are in %q", post.Slug, original.Slug, post.Lang))
+		case original.TranslationOf != "":
+			errs = append(errs, fmt.Errorf("post %q is a translation of %q, which is itself a translation of %q", post.Slug, original.Slug, original.TranslationOf))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// responsiveWidths are the widths generated for raster images when building
+// with -responsive-images. Only widths smaller than the original are produced.
+var responsiveWidths = []int{480, 960, 1600}
+
+var (
+	imgTagRegex  = regexp.MustCompile(`(?is)<img\b[^>]*>`)
+	urlAttrRegex = regexp.MustCompile(`(?i)(\s(?:src|href)\s*=\s*["'])(/[^/"'][^"']*|/)(["'])`)
+	srcsetRegex  = regexp.MustCompile(`(?i)(\ssrcset\s*=\s*["'])([^"']*)(["'])`)
+)
+
+// attrRegex matches a single attribute on a tag, capturing its value.
+func attrRegex(name string) *regexp.Regexp {
+	return regexp.MustCompile(`(?is)\s` + regexp.QuoteMeta(name) + `\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+}
+
+// getAttr returns the value of attribute name on an HTML start tag.
+func getAttr(tag, name string) (string, bool) {
+	m := attrRegex(name).FindStringSubmatch(tag)
+	if m == nil {
+		return "", false
+	}
+	return m[1] + m[2], true
+}
+
+// setAttr sets attribute name on an HTML start tag, replacing any existing
+// value. value must already be HTML-escaped.
+func setAttr(tag, name, value string) string {
+	attr := fmt.Sprintf(` %s="%s"`, name, value)
+	re := attrRegex(name)
+	if re.MatchString(tag) {
+		return re.ReplaceAllLiteralString(tag, attr)
+	}
+	end := strings.LastIndex(tag, ">")
+	if strings.HasSuffix(tag[:end], "/") {
+		end--
+	}
+	return tag[:end] + attr + tag[end:]
+}
+
+func isRelativeURL(url string) bool {
+	return url != "" && !strings.HasPrefix(url, "/") && !strings.HasPrefix(url, "#") &&
+		!strings.HasPrefix(url, "data:") && !strings.Contains(url, "://")
+}
+
+// rewriteAssetURLs points relative <img> sources in a directory-layout post
+// at the post's own URL, so "./hero.png" resolves the same way at
+// /post/<slug>, /post/<slug>/ and inside feeds.
+func rewriteAssetURLs(content, slug string) string {
+	return imgTagRegex.ReplaceAllStringFunc(content, func(tag string) string {
+		src, ok := getAttr(tag, "src")
+		if !ok || !isRelativeURL(src) {
+			return tag
+		}
+		return setAttr(tag, "src", path.Join("/post", slug, src))
+	})
+}
+
+var pictureRegex = regexp.MustCompile(`(?is)<picture\b.*?</picture>`)
+
+// addLoadingHints adds loading="lazy" and decoding="async" to images outside
+// code blocks that do not set them. Images inside <picture> are left
+// verbatim; posts with an above-the-fold hero can opt out entirely with
+// `eager-images: true`.
+func addLoadingHints(content string) string {
+	return transformOutsideCode(content, func(s string) string {
+		var b strings.Builder
+		last := 0
+		for _, loc := range pictureRegex.FindAllStringIndex(s, -1) {
+			b.WriteString(addLoadingAttrs(s[last:loc[0]]))
+			b.WriteString(s[loc[0]:loc[1]])
+			last = loc[1]
+		}
+		b.WriteString(addLoadingAttrs(s[last:]))
+		return b.String()
+	})
+}
+
+func addLoadingAttrs(s string) string {
+	return imgTagRegex.ReplaceAllStringFunc(s, func(tag string) string {
+		if _, ok := getAttr(tag, "loading"); !ok {
+			tag = setAttr(tag, "loading", "lazy")
+		}
+		if _, ok := getAttr(tag, "decoding"); !ok {
+			tag = setAttr(tag, "decoding", "async")
+		}
+		return tag
+	})
+}
+
+var (
+	// figureSkipRegex matches elements whose images are never wrapped:
+	// existing figures, links and <picture> sources.
+	figureSkipRegex = regexp.MustCompile(`(?is)<figure\b.*?</figure>|<a\b.*?</a>|<picture\b.*?</picture>`)
+	// standaloneImgRegex matches an image that is alone in its paragraph or
+	// on its own line. Images inside running text stay inline.
+	standaloneImgRegex = regexp.MustCompile(`(?is)<p\b[^>]*>\s*(<img\b[^>]*>)\s*</p>|(?m:^[ \t]*(<img\b[^>]*>)[ \t]*$)`)
+)
+
+// wrapFigures puts every standalone image with alt text in a <figure>
+// whose <figcaption> repeats the alt text, so it is visible to everyone.
+// Images that are already in a figure, a link or a <picture>, or that sit
+// inside a sentence, are left as they are.
+func wrapFigures(content string) string {
+	return transformOutsideCode(content, func(s string) string {
+		var b strings.Builder
+		last := 0
+		for _, loc := range figureSkipRegex.FindAllStringIndex(s, -1) {
+			b.WriteString(wrapStandaloneImages(s[last:loc[0]]))
+			b.WriteString(s[loc[0]:loc[1]])
+			last = loc[1]
+		}
+		b.WriteString(wrapStandaloneImages(s[last:]))
+		return b.String()
+	})
+}
+
+func wrapStandaloneImages(s string) string {
+	return standaloneImgRegex.ReplaceAllStringFunc(s, func(match string) string {
+		tag := imgTagRegex.FindString(match)
+		alt, _ := getAttr(tag, "alt")
+		caption := strings.TrimSpace(html.UnescapeString(alt))
+		if caption == "" {
+			return match
+		}
+		leading := match[:len(match)-len(strings.TrimLeft(match, " \t"))]
+		return fmt.Sprintf(`%s<figure class="post-figure">%s<figcaption>%s</figcaption></figure>`, leading, tag, html.EscapeString(caption))
+	})
+}
+
+// missingAltWarnings reports each <img> in a post's source that has no alt
+// text, with the line it starts on.
+func missingAltWarnings(file, source string) []string {
+	var warnings []string
+	for _, loc := range imgTagRegex.FindAllStringIndex(source, -1) {
+		tag := source[loc[0]:loc[1]]
+		if alt, ok := getAttr(tag, "alt"); ok && strings.TrimSpace(alt) != "" {
+			continue
+		}
+		src, _ := getAttr(tag, "src")
+		line := strings.Count(source[:loc[0]], "\n") + 1
+		warnings = append(warnings, fmt.Sprintf("%s:%d: image %q has no alt text", file, line, src))
+	}
+	return warnings
+}
+
+// absolutizeURLs turns root-relative src, href and srcset URLs into absolute
+// ones so that content embedded in feeds works outside the site.
+func absolutizeURLs(content, baseURL string) string {
+	content = urlAttrRegex.ReplaceAllString(content, "${1}"+baseURL+"${2}${3}")
+	return srcsetRegex.ReplaceAllStringFunc(content, func(attr string) string {
+		m := srcsetRegex.FindStringSubmatch(attr)
+		candidates := strings.Split(m[2], ",")
+		for i, candidate := range candidates {
+			candidate = strings.TrimSpace(candidate)
+			if strings.HasPrefix(candidate, "/") && !strings.HasPrefix(candidate, "//") {
+				candidate = baseURL + candidate
+			}
+			candidates[i] = candidate
+		}
+		return m[1] + strings.Join(candidates, ", ") + m[3]
+	})
+}
+
+// postAssets lists the non-HTML files stored alongside a directory-layout
+// post, relative to the post directory.
+func postAssets(dir string) ([]string, error) {
+	var assets []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(p, ".html") {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, p)
+		assets = append(assets, filepath.ToSlash(rel))
+		return nil
+	})
+	return assets, err
+}
+
+// copyPostAssets copies a post's co-located files into its output directory.
+func copyPostAssets(post *Post, outDir string) error {
+	srcDir := paths.content("posts", post.Slug)
+	for _, asset := range post.Assets {
+		dst := filepath.Join(outDir, filepath.FromSlash(asset))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		if err := copyFile(filepath.Join(srcDir, filepath.FromSlash(asset)), dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// imageResizer generates responsive variants during a build. It handles a
+// post's co-located images and images under /static/<prefix>, and remembers
+// results so an image shared by several posts is only resized once.
+type imageResizer struct {
+	outDir   string
+	prefix   string
+	variants map[string]imageVariants
+}
+
+func newImageResizer(outDir, imageDir string) *imageResizer {
+	prefix := path.Join("/static", imageDir) + "/"
+	return &imageResizer{outDir: outDir, prefix: prefix, variants: make(map[string]imageVariants)}
+}
+
+// rewrite generates variants for every eligible <img> in the post and adds
+// srcset and intrinsic width/height attributes to the tags. Images that
+// cannot be resized are reported and left as they are.
+func (ir *imageResizer) rewrite(post *Post) []string {
+	var skipped []string
+	for _, tag := range imgTagRegex.FindAllString(string(post.Content), -1) {
+		src, _ := getAttr(tag, "src")
+		if _, done := ir.variants[src]; done {
+			continue
+		}
+		if !strings.HasPrefix(src, ir.prefix) && !strings.HasPrefix(src, "/post/"+post.Slug+"/") {
+			continue
+		}
+		file, ok := imageFile(src)
+		if !ok {
+			continue
+		}
+		outDir := filepath.Join(ir.outDir, filepath.FromSlash(path.Dir(strings.TrimPrefix(src, "/"))))
+		v, err := generateVariants(file, outDir)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("skipping variants for %s: %v", src, err))
+		}
+		ir.variants[src] = v
+	}
+	post.Content = template.HTML(addSrcset(string(post.Content), ir.variants))
+	return skipped
+}
+
+// imageVariants records an image's intrinsic size and its resized copies,
+// keyed by width, as URLs relative to the original's directory.
+type imageVariants struct {
+	Width, Height int
+	Files         map[int]string
+}
+
+// generateVariants writes resized copies of the PNG or JPEG at file into
+// outDir, skipping widths the image is already smaller than. Other formats
+// return a zero imageVariants and no error.
+func generateVariants(file, outDir string) (imageVariants, error) {
+	ext := strings.ToLower(filepath.Ext(file))
+	if ext != ".png" && ext != ".jpg" && ext != ".jpeg" {
+		return imageVariants{}, nil
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return imageVariants{}, err
+	}
+	src, _, err := image.Decode(f)
+	f.Close()
+	if err != nil {
+		return imageVariants{}, err
+	}
+
+	bounds := src.Bounds()
+	v := imageVariants{Width: bounds.Dx(), Height: bounds.Dy(), Files: make(map[int]string)}
+	base := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	for _, width := range responsiveWidths {
+		if width >= v.Width {
+			continue
+		}
+		name := fmt.Sprintf("%s-%dw%s", base, width, filepath.Ext(file))
+		if err := os.MkdirAll(outDir, 0755); err != nil {
+			return imageVariants{}, err
+		}
+		if err := writeImage(filepath.Join(outDir, name), resizeImage(src, width), ext); err != nil {
+			return imageVariants{}, err
+		}
+		v.Files[width] = name
+	}
+	return v, nil
+}
+
+func writeImage(file string, img image.Image, ext string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if ext == ".png" {
+		return png.Encode(f, img)
+	}
+	return jpeg.Encode(f, img, &jpeg.Options{Quality: 85})
+}
+
+// resizeImage scales src to the given width, preserving aspect ratio, by
+// averaging the source pixels that fall under each destination pixel.
+func resizeImage(src image.Image, width int) *image.NRGBA {
+	bounds := src.Bounds()
+	in := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(in, in.Bounds(), src, bounds.Min, draw.Src)
+
+	height := max(1, bounds.Dy()*width/bounds.Dx())
+	out := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*bounds.Dy()/height, max((y+1)*bounds.Dy()/height, y*bounds.Dy()/height+1)
+		for x := 0; x < width; x++ {
+			x0, x1 := x*bounds.Dx()/width, max((x+1)*bounds.Dx()/width, x*bounds.Dx()/width+1)
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					i := in.PixOffset(sx, sy)
+					r += int(in.Pix[i])
+					g += int(in.Pix[i+1])
+					b += int(in.Pix[i+2])
+					a += int(in.Pix[i+3])
+					n++
+				}
+			}
+			i := out.PixOffset(x, y)
+			out.Pix[i], out.Pix[i+1], out.Pix[i+2], out.Pix[i+3] = uint8(r/n), uint8(g/n), uint8(b/n), uint8(a/n)
+		}
+	}
+	return out
+}
+
+// addSrcset adds srcset, sizes and width/height to every <img> whose src has
+// generated variants. The original src is kept as the fallback.
+func addSrcset(content string, variants map[string]imageVariants) string {
+	return imgTagRegex.ReplaceAllStringFunc(content, func(tag string) string {
+		src, _ := getAttr(tag, "src")
+		v, ok := variants[src]
+		if !ok || v.Width == 0 {
+			return tag
+		}
+		if _, ok := getAttr(tag, "width"); !ok {
+			tag = setAttr(tag, "width", fmt.Sprint(v.Width))
+			tag = setAttr(tag, "height", fmt.Sprint(v.Height))
+		}
+		if len(v.Files) == 0 {
+			return tag
+		}
+		var candidates []string
+		for _, width := range responsiveWidths {
+			if name, ok := v.Files[width]; ok {
+				candidates = append(candidates, fmt.Sprintf("%s %dw", path.Join(path.Dir(src), name), width))
+			}
+		}
+		candidates = append(candidates, fmt.Sprintf("%s %dw", src, v.Width))
+		tag = setAttr(tag, "srcset", strings.Join(candidates, ", "))
+		return setAttr(tag, "sizes", "(max-width: 640px) 100vw, 640px")
+	})
+}
+
+// resolveImageURL normalizes an `image` metadata value to a root-relative URL.
+// Bare paths are relative to static/, except in directory-layout posts where
+// they refer to the post's own assets.
+func resolveImageURL(image, slug string, isDir bool) string {
+	switch {
+	case image == "" || !isRelativeURL(image):
+		return image
+	case isDir:
+		return path.Join("/post", slug, image)
+	default:
+		return path.Join("/static", image)
+	}
+}
+
+// imageFile maps a root-relative image URL back to its source file.
+func imageFile(url string) (string, bool) {
+	switch {
+	case strings.HasPrefix(url, "/static/"):
+		return paths.content(filepath.FromSlash(strings.TrimPrefix(url, "/"))), true
+	case strings.HasPrefix(url, "/post/"):
+		return paths.content("posts", filepath.FromSlash(strings.TrimPrefix(url, "/post/"))), true
+	}
+	return "", false
+}
+
+// measurePostImage fills in ImageWidth and ImageHeight from the featured
+// image file. Missing or undecodable images are left unmeasured, and the
+// error reported, so the page still gets the bare og:image URL.
+func measurePostImage(post *Post) error {
+	file, ok := imageFile(post.Image)
+	if !ok {
+		return nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("could not open image: %w", err)
+	}
+	defer f.Close()
+	config, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return fmt.Errorf("could not decode image %s: %w", file, err)
+	}
+	post.ImageWidth, post.ImageHeight = config.Width, config.Height
+	return nil
+}
+
+// absoluteURL resolves a root-relative URL against baseURL and the base
+// path, leaving absolute URLs untouched.
+func absoluteURL(baseURL, url string) string {
+	if strings.HasPrefix(url, "/") && !strings.HasPrefix(url, "//") {
+		return strings.TrimSuffix(baseURL, "/") + sitePath(url)
+	}
+	return url
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+var (
+	includeRegex      = regexp.MustCompile(`<!--\s*include:\s*(\S+)((?:\s+[\w-]+="[^"]*")*)\s*-->`)
+	includeParamRegex = regexp.MustCompile(`([\w-]+)="([^"]*)"`)
+)
+
+// expandIncludes replaces every `<!-- include: partials/name.html -->`
+// outside code blocks with that file from the partials/ directory. Any
+// key="value" pairs after the path are passed to the partial, which is run
+// through text/template, so `title="Heads up"` fills {{.title}}. Partials may
+// include other partials; a missing file or an include cycle is an error.
+func expandIncludes(content string) (string, error) {
+	return expandIncludesFrom(content, nil)
+}
+
+func expandIncludesFrom(content string, stack []string) (string, error) {
+	if !strings.Contains(content, "include:") {
+		return content, nil
+	}
+	var firstErr error
+	content = transformOutsideCode(content, func(s string) string {
+		return includeRegex.ReplaceAllStringFunc(s, func(match string) string {
+			if firstErr != nil {
+				return match
+			}
+			m := includeRegex.FindStringSubmatch(match)
+			expanded, err := renderPartial(m[1], m[2], stack)
+			if err != nil {
+				firstErr = err
+				return match
+			}
+			return expanded
+		})
+	})
+	return content, firstErr
+}
+
+func renderPartial(name, rawParams string, stack []string) (string, error) {
+	clean := path.Clean(name)
+	if !strings.HasPrefix(clean, "partials/") {
+		return "", fmt.Errorf("include %q: partials must live under partials/", name)
+	}
+	for _, parent := range stack {
+		if parent == clean {
+			return "", fmt.Errorf("include %q: recursive include (%s -> %s)", name, strings.Join(stack, " -> "), clean)
+		}
+	}
+
+	source, err := os.ReadFile(paths.content(clean))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("include %q: partial not found", name)
+		}
+		return "", fmt.Errorf("include %q: %w", name, err)
+	}
+
+	params := make(map[string]string)
+	for _, p := range includeParamRegex.FindAllStringSubmatch(rawParams, -1) {
+		params[p[1]] = p[2]
+	}
+	tmpl, err := template.New(clean).Option("missingkey=zero").Parse(string(source))
+	if err != nil {
+		return "", fmt.Errorf("include %q: %w", name, err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, params); err != nil {
+		return "", fmt.Errorf("include %q: %w", name, err)
+	}
+
+	return expandIncludesFrom(b.String(), append(stack[:len(stack):len(stack)], clean))
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// renderKey hashes what every page depends on besides its own data: the
+// binary, the site config, the templates, the static files behind asset
+// URLs and the inlined critical CSS. It includes today's date, since
+// relativeTime counts from it.
+func renderKey() string {
+	h := sha256.New()
+	config, _ := json.Marshal(site)
+	for _, s := range []string{currentBuild.Version, currentBuild.Commit, string(config), site.Location.String(),
+		string(inlineCSS), now().In(site.Location).Format("2006-01-02")} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	filepath.WalkDir(paths.Templates, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			content, _ := os.ReadFile(path)
+			h.Write([]byte(path))
+			h.Write(content)
+		}
+		return nil
+	})
+	filepath.WalkDir(paths.content("static"), func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			hash, _ := staticHashes.get(path)
+			h.Write([]byte(path + hash))
+		}
+		return nil
+	})
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// inputKey hashes the data an output is rendered from, on top of the
+// build's renderKey. Data that cannot be encoded gets no key, so the output
+// is always rebuilt.
+func (r *buildReport) inputKey(name string, data any) string {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(r.renderKey + "\x00" + name + "\x00" + string(encoded)))
+	return hex.EncodeToString(sum[:])
+}
+
+// writeKeyed is write for outputs with an input key. In an incremental
+// build, an output whose key matches the previous build's and whose file
+// is still there is recorded as unchanged without running fn.
+func (r *buildReport) writeKeyed(kind, outputPath, source, key string, fn func() error) error {
+	rel := r.rel(outputPath)
+	if key != "" {
+		r.inputs[rel] = key
+	}
+	if r.previous != nil && key != "" && r.previous[rel] == key {
+		if _, err := os.Stat(outputPath); err == nil {
+			r.unchanged[rel] = true
+			r.record(kind, outputPath, source, 0)
+			return nil
+		}
+	}
+	return r.write(kind, outputPath, source, fn)
+}
+
+// writePage renders a page with the layout and contentPath, keyed on both
+// templates and data.
+func (r *buildReport) writePage(kind, outputPath, source, contentPath string, data any) error {
+	return r.writeKeyed(kind, outputPath, source, r.inputKey(contentPath, data), func() error {
+		return buildPage(outputPath, paths.template("layout.html"), contentPath, data)
+	})
+}
+
+// removeStale deletes what an incremental build left behind from earlier
+// ones: files it neither wrote nor kept, such as the pages of deleted
+// posts. Image variants are rewritten on every build, so anything else
+// older than the build is stale. Directories it empties go too.
+func (r *buildReport) removeStale() error {
+	// Allow for filesystems that store mtimes to the second or coarser.
+	cutoff := r.start.Add(-2 * time.Second)
+	var stale []string
+	err := filepath.WalkDir(r.outDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || r.recorded[r.rel(path)] || r.rel(path) == manifestFile {
+			return err
+		}
+		if info, err := d.Info(); err == nil && info.ModTime().Before(cutoff) {
+			stale = append(stale, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, path := range stale {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		if r.mode == reportVerbose {
+			fmt.Fprintf(r.out, "  %-50s %9s\n", r.rel(path), "removed")
+		}
+		for dir := filepath.Dir(path); dir != r.outDir && len(dir) > len(r.outDir); dir = filepath.Dir(dir) {
+			if os.Remove(dir) != nil {
+				break
+			}
+		}
+	}
+	return nil
+}
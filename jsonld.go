@@ -0,0 +1,80 @@
+package main
+
+import (
+	"html/template"
+	"strings"
+	"time"
+)
+
+// jsonLDPosting is the schema.org BlogPosting embedded in post pages for
+// search engines. Unset fields are omitted rather than sent empty.
+type jsonLDPosting struct {
+	Context       string          `json:"@context"`
+	Type          string          `json:"@type"`
+	Headline      string          `json:"headline"`
+	Description   string          `json:"description,omitempty"`
+	URL           string          `json:"url,omitempty"`
+	Image         string          `json:"image,omitempty"`
+	DatePublished string          `json:"datePublished,omitempty"`
+	DateModified  string          `json:"dateModified,omitempty"`
+	InLanguage    string          `json:"inLanguage,omitempty"`
+	Keywords      string          `json:"keywords,omitempty"`
+	Author        []jsonLDPerson  `json:"author,omitempty"`
+	IsPartOf      *jsonLDCreative `json:"isPartOf,omitempty"`
+	Position      int             `json:"position,omitempty"` // place in the collection
+}
+
+type jsonLDPerson struct {
+	Type string `json:"@type"`
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+type jsonLDCreative struct {
+	Type string `json:"@type"`
+	Name string `json:"name,omitempty"`
+	URL  string `json:"url"`
+}
+
+// newPostJSONLD describes post as a BlogPosting. A post in a collection is
+// marked as part of that series, with its position in it.
+func newPostJSONLD(post Post, baseURL string) jsonLDPosting {
+	ld := jsonLDPosting{
+		Context:     "https://schema.org",
+		Type:        "BlogPosting",
+		Headline:    post.Title,
+		Description: post.ExcerptText,
+		URL:         canonicalURL(baseURL, "/post/"+post.Slug),
+		Image:       post.ImageURL,
+		InLanguage:  post.Lang,
+		Keywords:    strings.Join(post.Tags, ", "),
+	}
+	if !post.Published.IsZero() {
+		ld.DatePublished = post.Published.Format(time.RFC3339)
+	}
+	if !post.UpdatedAt.IsZero() {
+		ld.DateModified = post.UpdatedAt.Format(time.RFC3339)
+	}
+	for _, author := range post.Authors {
+		person := jsonLDPerson{Type: "Person", Name: author.Name, URL: author.URL}
+		if person.URL == "" && author.Slug != "" {
+			person.URL = canonicalURL(baseURL, "/author/"+author.Slug)
+		}
+		ld.Author = append(ld.Author, person)
+	}
+	if post.Collection != "" {
+		ld.IsPartOf = &jsonLDCreative{
+			Type: "CreativeWorkSeries",
+			Name: post.CollectionTitle,
+			URL:  canonicalURL(baseURL, "/collection/"+post.Collection),
+		}
+		ld.Position = post.CollectionIndex
+	}
+	return ld
+}
+
+// postJSONLD is newPostJSONLD encoded for the layout's
+// <script type="application/ld+json">.
+func postJSONLD(post Post, baseURL string) template.JS {
+	return jsonify(newPostJSONLD(post, baseURL))
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPostJSONLD(t *testing.T) {
+	post := Post{
+		Slug:            "caching",
+		Title:           "Caching in Go",
+		ExcerptText:     "How the cache works.",
+		ImageURL:        "https://example.com/static/img/cache.png",
+		Lang:            "en",
+		Tags:            []string{"go", "performance"},
+		Published:       time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC),
+		UpdatedAt:       time.Date(2024, 4, 1, 12, 30, 0, 0, time.UTC),
+		Collection:      "internals",
+		CollectionTitle: "Internals",
+		CollectionIndex: 2,
+		Authors: []Author{
+			{Slug: "sam", Name: "Sam"},
+			{Slug: "ri", Name: "Ri", URL: "https://ri.example"},
+		},
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal([]byte(postJSONLD(post, "https://example.com")), &got); err != nil {
+		t.Fatalf("postJSONLD output is not JSON: %v", err)
+	}
+	for key, want := range map[string]any{
+		"@context":      "https://schema.org",
+		"@type":         "BlogPosting",
+		"headline":      "Caching in Go",
+		"description":   "How the cache works.",
+		"url":           "https://example.com/post/caching",
+		"image":         "https://example.com/static/img/cache.png",
+		"datePublished": "2024-03-05T00:00:00Z",
+		"dateModified":  "2024-04-01T12:30:00Z",
+		"inLanguage":    "en",
+		"keywords":      "go, performance",
+		"position":      2.0,
+	} {
+		if got[key] != want {
+			t.Errorf("%s = %#v, want %#v", key, got[key], want)
+		}
+	}
+
+	authors, _ := got["author"].([]any)
+	if len(authors) != 2 {
+		t.Fatalf("author = %#v, want two people", got["author"])
+	}
+	for i, want := range []map[string]any{
+		{"@type": "Person", "name": "Sam", "url": "https://example.com/author/sam"},
+		{"@type": "Person", "name": "Ri", "url": "https://ri.example"},
+	} {
+		person, _ := authors[i].(map[string]any)
+		for key, value := range want {
+			if person[key] != value {
+				t.Errorf("author[%d].%s = %#v, want %#v", i, key, person[key], value)
+			}
+		}
+	}
+
+	series, _ := got["isPartOf"].(map[string]any)
+	if series["@type"] != "CreativeWorkSeries" || series["name"] != "Internals" || series["url"] != "https://example.com/collection/internals" {
+		t.Errorf("isPartOf = %#v", got["isPartOf"])
+	}
+}
+
+func TestPostJSONLDOmitsUnsetFields(t *testing.T) {
+	var got map[string]any
+	if err := json.Unmarshal([]byte(postJSONLD(Post{Slug: "bare", Title: "Bare"}, "https://example.com")), &got); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"image", "datePublished", "dateModified", "author", "isPartOf", "position", "keywords"} {
+		if _, ok := got[key]; ok {
+			t.Errorf("%s = %#v, want it left out", key, got[key])
+		}
+	}
+}
+
+func TestPostJSONLDEscapesScriptEnd(t *testing.T) {
+	title := `Ending </script><script>alert(1)</script> early`
+	out := string(postJSONLD(Post{Slug: "x", Title: title}, "https://example.com"))
+	if strings.Contains(strings.ToLower(out), "</script") {
+		t.Errorf("output can close the script element: %s", out)
+	}
+	var got map[string]any
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["headline"] != title {
+		t.Errorf("headline = %#v, want %q", got["headline"], title)
+	}
+}
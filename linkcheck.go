@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	linkAttrRegex  = regexp.MustCompile(`(?i)\s(?:href|src)\s*=\s*["']([^"']*)["']`)
+	elementIDRegex = regexp.MustCompile(`(?i)\sid\s*=\s*["']([^"']*)["']`)
+	scriptRegex    = regexp.MustCompile(`(?is)<script\b.*?</script>`)
+)
+
+// runCheck verifies the links in an existing build, printing one line per
+// broken reference grouped by page, and fails if there are any.
+func runCheck(distDir string, external bool, timeout time.Duration, concurrency int) error {
+	if _, err := os.Stat(filepath.Join(distDir, "index.html")); err != nil {
+		return fmt.Errorf("no build found in %s, run build first", distDir)
+	}
+	broken, err := checkLinks(distDir)
+	if err != nil {
+		return err
+	}
+	if external {
+		externalBroken, err := checkExternalLinks(distDir, timeout, concurrency)
+		if err != nil {
+			return err
+		}
+		broken = append(broken, externalBroken...)
+		sort.Strings(broken)
+	}
+	for _, problem := range broken {
+		fmt.Println(problem)
+	}
+	if len(broken) > 0 {
+		return fmt.Errorf("found %d broken links", len(broken))
+	}
+	fmt.Println("No broken links.")
+	return nil
+}
+
+// checkLinks scans every HTML page under distDir for root-relative href and
+// src attributes and returns a description of each one whose target file or
+// #fragment does not exist in the build. External links are skipped.
+func checkLinks(distDir string) ([]string, error) {
+	pages, err := readDistPages(distDir)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]map[string]bool, len(pages))
+	for file, page := range pages {
+		ids[file] = make(map[string]bool)
+		for _, m := range elementIDRegex.FindAllStringSubmatch(page, -1) {
+			ids[file][html.UnescapeString(m[1])] = true
+		}
+	}
+
+	var broken []string
+	for file, page := range pages {
+		for _, href := range pageLinks(page) {
+			if href == "" || isExternalLink(href) {
+				continue
+			}
+
+			target, fragment, _ := strings.Cut(href, "#")
+			target, _, _ = strings.Cut(target, "?")
+			if unescaped, err := url.PathUnescape(target); err == nil {
+				target = unescaped
+			}
+			if unescaped, err := url.PathUnescape(fragment); err == nil {
+				fragment = unescaped
+			}
+			targetFile := file
+			if target != "" {
+				if !strings.HasPrefix(target, "/") {
+					continue
+				}
+				var ok bool
+				if targetFile, ok = resolveDistPath(distDir, target); !ok {
+					broken = append(broken, fmt.Sprintf("%s: broken link %s", file, href))
+					continue
+				}
+			}
+			if fragment != "" && strings.HasSuffix(targetFile, ".html") && !ids[targetFile][fragment] {
+				broken = append(broken, fmt.Sprintf("%s: missing anchor %s", file, href))
+			}
+		}
+	}
+
+	sort.Strings(broken)
+	return broken, nil
+}
+
+// checkExternalLinks sends a HEAD request to every distinct http(s) URL
+// linked from the pages under distDir, at most concurrency at a time, and
+// describes each failing reference. Links to the site's own base URL, such
+// as canonical links, are skipped since they point at the deployed copy.
+// Servers that reject HEAD are retried with GET.
+func checkExternalLinks(distDir string, timeout time.Duration, concurrency int) ([]string, error) {
+	pages, err := readDistPages(distDir)
+	if err != nil {
+		return nil, err
+	}
+	self, _ := url.Parse(site.BaseURL)
+	referrers := make(map[string][]string) // URL -> files linking to it
+	for file, page := range pages {
+		for _, href := range pageLinks(page) {
+			u, err := url.Parse(href)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || (self != nil && strings.EqualFold(u.Host, self.Host)) {
+				continue
+			}
+			referrers[href] = append(referrers[href], file)
+		}
+	}
+
+	client := &http.Client{Timeout: timeout}
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		failures = make(map[string]string)
+		slots    = make(chan struct{}, max(concurrency, 1))
+	)
+	for link := range referrers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			if problem := probeURL(client, link); problem != "" {
+				mu.Lock()
+				failures[link] = problem
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	var broken []string
+	for link, problem := range failures {
+		seen := make(map[string]bool)
+		for _, file := range referrers[link] {
+			if !seen[file] {
+				seen[file] = true
+				broken = append(broken, fmt.Sprintf("%s: broken external link %s (%s)", file, link, problem))
+			}
+		}
+	}
+	sort.Strings(broken)
+	return broken, nil
+}
+
+// probeURL returns why link is unreachable, or "" if it answers below 400.
+func probeURL(client *http.Client, link string) string {
+	var status int
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		req, err := http.NewRequest(method, link, nil)
+		if err != nil {
+			return err.Error()
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			if urlErr, ok := err.(*url.Error); ok {
+				return urlErr.Err.Error()
+			}
+			return err.Error()
+		}
+		resp.Body.Close()
+		status = resp.StatusCode
+		if status != http.StatusMethodNotAllowed && status != http.StatusNotImplemented {
+			break
+		}
+	}
+	if status >= 400 {
+		return http.StatusText(status)
+	}
+	return ""
+}
+
+// pageLinks returns the href and src values in page with HTML entities
+// decoded, in document order.
+func pageLinks(page string) []string {
+	var links []string
+	for _, m := range linkAttrRegex.FindAllStringSubmatch(page, -1) {
+		links = append(links, strings.TrimSpace(html.UnescapeString(m[1])))
+	}
+	return links
+}
+
+// readDistPages reads every HTML page under distDir, keyed by its
+// dist-relative path.
+func readDistPages(distDir string) (map[string]string, error) {
+	pages := make(map[string]string)
+	err := filepath.WalkDir(distDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".html") {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(distDir, path)
+		// Markup built inside inline scripts is not part of the page.
+		pages[filepath.ToSlash(rel)] = scriptRegex.ReplaceAllString(string(content), "")
+		return nil
+	})
+	return pages, err
+}
+
+func isExternalLink(href string) bool {
+	lower := strings.ToLower(href)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") ||
+		strings.HasPrefix(lower, "//") || strings.HasPrefix(lower, "mailto:") || strings.HasPrefix(lower, "tel:")
+}
+
+// resolveDistPath maps a root-relative URL path under the base path to the
+// built file that a static host would serve for it.
+func resolveDistPath(distDir, urlPath string) (string, bool) {
+	urlPath, ok := strings.CutPrefix(urlPath, site.BasePath)
+	if !ok || (urlPath != "" && !strings.HasPrefix(urlPath, "/")) {
+		return "", false
+	}
+	rel := strings.Trim(urlPath, "/")
+	candidates := []string{rel, filepath.ToSlash(filepath.Join(rel, "index.html"))}
+	if rel == "" {
+		candidates = []string{"index.html"}
+	}
+	for _, candidate := range candidates {
+		if info, err := os.Stat(filepath.Join(distDir, candidate)); err == nil && !info.IsDir() {
+			return candidate, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckLinksReportsMissingPost(t *testing.T) {
+	newTestSite(t, map[string]string{
+		"posts/linker.html": testPost("Linker", "2024-01-02", `<p>See <a href="/post/renamed-away">the old post</a> and <a href="/post/target#intro">the intro</a>.</p>`),
+		"posts/target.html": testPost("Target", "2024-01-01", `<h2>Intro</h2><p>Text.</p>`),
+	})
+	if err := buildTestSite(t, buildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	broken, err := checkLinks(paths.Out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "post/linker/index.html: broken link /post/renamed-away"
+	if len(broken) != 1 || broken[0] != want {
+		t.Errorf("checkLinks() = %q, want [%q]", broken, want)
+	}
+}
+
+func TestStrictBuildFailsOnBrokenLink(t *testing.T) {
+	newTestSite(t, map[string]string{
+		"posts/linker.html": testPost("Linker", "2024-01-02", `<p><a href="/post/missing-slug">gone</a></p>`),
+	})
+	if err := buildTestSite(t, buildOptions{}); err != nil {
+		t.Fatalf("non-strict build failed: %v", err)
+	}
+	err := buildTestSite(t, buildOptions{Strict: true})
+	if err == nil || !strings.Contains(err.Error(), "broken internal links") {
+		t.Errorf("strict build error = %v, want a broken link failure", err)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"html"
+	"html/template"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var anchorTagRegex = regexp.MustCompile(`(?is)<a\b[^>]*>`)
+
+// processLinks rewrites the anchors in post content outside code blocks.
+// Absolute links to the site's own host become root-relative, without the
+// base path, so content works on staging and production alike. Links to other hosts get
+// rel="noopener noreferrer", merged into any existing rel, plus the
+// configured target and class.
+func processLinks(content, baseURL string) string {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return content
+	}
+	return transformOutsideCode(content, func(s string) string {
+		return anchorTagRegex.ReplaceAllStringFunc(s, func(tag string) string {
+			href, ok := getAttr(tag, "href")
+			if !ok {
+				return tag
+			}
+			u, err := url.Parse(html.UnescapeString(strings.TrimSpace(href)))
+			if err != nil || u.Host == "" || (u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https") {
+				return tag
+			}
+			if strings.EqualFold(u.Host, base.Host) {
+				i := strings.Index(strings.ToLower(href), strings.ToLower(u.Host)) + len(u.Host)
+				rel := href[i:]
+				if rel == "" || !strings.HasPrefix(rel, "/") {
+					rel = "/" + rel
+				}
+				if rest, ok := strings.CutPrefix(rel, site.BasePath); ok && site.BasePath != "" &&
+					(rest == "" || strings.ContainsAny(rest[:1], "/?#")) {
+					rel = "/" + strings.TrimPrefix(rest, "/")
+				}
+				return setAttr(tag, "href", rel)
+			}
+
+			tag = mergeAttrTokens(tag, "rel", "noopener", "noreferrer")
+			if site.ExternalLinkClass != "" {
+				tag = mergeAttrTokens(tag, "class", html.EscapeString(site.ExternalLinkClass))
+			}
+			if _, ok := getAttr(tag, "target"); !ok && site.ExternalLinksNewTab {
+				tag = setAttr(tag, "target", "_blank")
+			}
+			return tag
+		})
+	})
+}
+
+// withBasePath prefixes the root-relative src, href and srcset URLs in post
+// content outside code blocks with the base path. Content is stored with
+// site-root URLs such as "/post/slug" and only prefixed when it is served.
+func withBasePath(content template.HTML) template.HTML {
+	if site.BasePath == "" {
+		return content
+	}
+	return template.HTML(transformOutsideCode(string(content), func(s string) string {
+		return absolutizeURLs(s, site.BasePath)
+	}))
+}
+
+// mergeAttrTokens adds space-separated tokens to an attribute such as rel or
+// class, keeping existing tokens and their order.
+func mergeAttrTokens(tag, name string, tokens ...string) string {
+	value, _ := getAttr(tag, name)
+	existing := strings.Fields(value)
+	for _, token := range tokens {
+		found := false
+		for _, e := range existing {
+			if strings.EqualFold(e, token) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			existing = append(existing, token)
+		}
+	}
+	return setAttr(tag, name, strings.Join(existing, " "))
+}
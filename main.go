@@ -1,37 +1,73 @@
 package main
 
 import (
+	"context"
 	"encoding/xml"
+	"errors"
+	"flag"
 	"fmt"
 	"html/template"
 	"io/fs"
 	"log"
+	"log/slog"
 	"math"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
+	"unicode"
 
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 )
 
+// logger writes the server's structured log lines. CLI subcommands keep
+// printing plain progress text.
+var logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+
 var templateFuncs = template.FuncMap{
+	// formatSlug turns a slug into a heading. NoLower keeps acronyms such as
+	// "API" intact, and the caser handles apostrophes ("don't" → "Don't")
+	// and non-ASCII letters. Casers are stateful, so one is made per call.
 	"formatSlug": func(s string) string {
 		s = strings.ReplaceAll(s, "-", " ")
 		s = strings.ReplaceAll(s, "_", " ")
-		return cases.Title(language.English).String(s)
+		return cases.Title(language.English, cases.NoLower).String(s)
 	},
-	"hashColor": func(s string) int {
-		var hash uint32
-		for _, c := range s {
-			hash = hash*31 + uint32(c)
-		}
-		return int(hash % 5)
+	"site": func() SiteConfig {
+		return site
 	},
+	"hashColor":   hashColor,
+	"asset":       assetURL,
+	"criticalCSS": criticalCSS,
+
+	// Theme helpers, documented in funcs.go.
+	"dateFormat":    dateFormat,
+	"truncateWords": truncateWords,
+	"slugify":       slugify,
+	"absURL":        absURL,
+	"pluralize":     pluralize,
+	"jsonify":       jsonify,
+	"relativeTime":  relativeTime,
+}
+
+// hashColor picks one of the five palette colors for a slug, so a
+// collection's badge, card and share images always match.
+func hashColor(s string) int {
+	var hash uint32
+	for _, c := range s {
+		hash = hash*31 + uint32(c)
+	}
+	return int(hash % 5)
 }
 
 func parseTemplates(files ...string) (*template.Template, error) {
@@ -40,52 +76,138 @@ func parseTemplates(files ...string) (*template.Template, error) {
 
 type Post struct {
 	Slug                  string
+	Meta                  map[string]string
 	Title                 string
+	Author                string
+	AuthorName            string
+	AuthorBio             string
+	Authors               []Author
 	Description           template.HTML
-	Date                  string
-	RawDate               string
+	Excerpt               template.HTML
+	ExcerptText           string
+	Date                  string    // "January 2, 2006"
+	RawDate               string    // "2006-01-02" in the site timezone
+	Published             time.Time // zero when the date could not be parsed
+	Updated               string    // "January 2, 2006", empty when never revised
+	RawUpdated            string    // "2006-01-02", empty when never revised
+	UpdatedAt             time.Time // zero when never revised
 	Collection            string
+	Tags                  []string
 	CollectionTitle       string
 	CollectionDescription template.HTML
 	CollectionIndex       int
 	CollectionTotal       int
+	CollectionPlanned     int    // the collection's `planned` metadata, 0 when not given
+	CollectionStatus      string // the collection's `status` metadata
 	Content               template.HTML
 	ReadTimeInMinutes     int
-	TOC                   []TOCItem
-	PageType              string
+	ReadTimeLabel         string
+	WordCount             int       // prose words, counted as readingMinutes counts them
+	TOC                   []TOCItem // empty when ShowTOC is false
+	ShowTOC               bool      // enough headings, and not turned off with `toc: false`
+	Aliases               []string
+	Layout                string // content template from `layout` metadata, empty for post.html
+	Draft                 bool
+	Pinned                bool
+	Order                 int // position within the collection from `order`, 0 when unset
+	Lang                  string
+	TranslationOf         string // slug of the original this post translates
+	Translations          []Translation
+	Assets                []string
+	ExtraStyles           []string // stylesheets under static/ from `styles`, linked on this post's page only
+	ExtraScripts          []string // scripts under static/ from `scripts`, loaded on this post's page only
+	Image                 string
+	ImageURL              string
+	ImageWidth            int
+	ImageHeight           int
+	Warnings              []string
+	StructuredData        template.JS // BlogPosting JSON-LD for the page head
+	PageMeta
 }
 
 type TOCItem struct {
-	ID    string
-	Text  string
-	Level int
+	ID         string    `json:"id"`
+	Text       string    `json:"text"`
+	Level      int       `json:"level"`
+	WordOffset int       `json:"wordOffset"` // prose words before the heading
+	Children   []TOCItem `json:"children,omitempty"`
+}
+
+// MinutesIn is roughly how far into the post the heading sits at the
+// configured reading speed.
+func (item TOCItem) MinutesIn() int {
+	return int(math.Round(float64(item.WordOffset) / float64(site.WordsPerMinute)))
 }
 
 type Collection struct {
-	Slug            string
-	Title           string
-	Description     template.HTML
-	DescriptionText string
-	Posts           []Post
-	PageType        string
+	Slug                 string
+	Title                string
+	Description          template.HTML
+	DescriptionText      string
+	Posts                []Post
+	Parent               string // slug of the umbrella collection, if any
+	ParentTitle          string
+	Children             []Collection // sub-collections, complete ones last, then by title
+	Planned              int          // `planned` metadata, 0 when not given
+	Status               string       // "complete", "ongoing", "paused" or empty
+	PercentComplete      int          // published posts against Planned, capped at 100
+	PostCount            int          // published posts in the whole collection, not just this page
+	TotalWordCount       int          // WordCount summed over the published posts
+	TotalReadTimeMinutes int          // ReadTimeInMinutes summed over the published posts
+	Pagination           Pagination   // the page of Posts on a collection page
+	Warnings             []string
+	PageMeta
 }
 
 type IndexData struct {
 	Title    string
+	Lang     string
+	Featured []Post
 	Posts    []Post
-	PageType string
+	PageMeta
+}
+
+// PageMeta holds the page-level fields shared by every template data struct
+// so the layout can rely on them regardless of page type.
+type PageMeta struct {
+	PageType  string
+	Canonical string
+	Lang      string     // <html lang>; posts and language indexes shadow it with their own Lang
+	Feeds     []FeedLink // advertised in the head for feed autodiscovery
+	Build     BuildInfo  // the binary and build that rendered the page
+}
+
+// FeedLink is an RSS feed a page links to with <link rel="alternate">.
+type FeedLink struct {
+	Title string
+	URL   string
+}
+
+// newPageMeta fills in what every page shares: its type, its canonical URL
+// at path, and the site feed.
+func newPageMeta(pageType, baseURL, path string) PageMeta {
+	return PageMeta{
+		PageType:  pageType,
+		Canonical: canonicalURL(baseURL, path),
+		Lang:      site.Language,
+		Feeds:     []FeedLink{{Title: site.Title, URL: canonicalURL(baseURL, "/feed.xml")}},
+		Build:     currentBuild,
+	}
 }
 
 type RSS struct {
-	XMLName xml.Name `xml:"rss"`
-	Version string   `xml:"version,attr"`
-	Channel *Channel `xml:"channel"`
+	XMLName   xml.Name `xml:"rss"`
+	Version   string   `xml:"version,attr"`
+	DCNS      string   `xml:"xmlns:dc,attr"`
+	ContentNS string   `xml:"xmlns:content,attr,omitempty"` // declares content:encoded, only with FEED_MODE=full
+	Channel   *Channel `xml:"channel"`
 }
 
 type Channel struct {
 	Title       string `xml:"title"`
 	Link        string `xml:"link"`
 	Description string `xml:"description"`
+	Language    string `xml:"language,omitempty"`
 	Items       []Item `xml:"item"`
 }
 
@@ -93,52 +215,277 @@ type Item struct {
 	Title       string `xml:"title"`
 	Link        string `xml:"link"`
 	Description string `xml:"description"`
-	PubDate     string `xml:"pubDate"`
+	Creator     string `xml:"dc:creator,omitempty"`
+	PubDate     string `xml:"pubDate,omitempty"`
 	GUID        string `xml:"guid"`
+	// Content is the full post HTML with absolute URLs, with FEED_MODE=full.
+	// It goes in the RSS content module's <content:encoded>, which readers
+	// show in place of the description.
+	Content string `xml:"content:encoded,omitempty"`
 }
 
 type CollectionsData struct {
 	Title       string
 	Collections []Collection
-	PageType    string
+	PageMeta
+}
+
+type NotFoundData struct {
+	Title string
+	PageMeta
+}
+
+// newNotFoundData is the 404 page. It has no canonical URL since static
+// hosts serve it at whatever path was missing.
+func newNotFoundData(baseURL string) NotFoundData {
+	meta := newPageMeta("notfound", baseURL, "/")
+	meta.Canonical = ""
+	return NotFoundData{Title: "Page not found", PageMeta: meta}
 }
 
 func main() {
-	if len(os.Args) > 1 && os.Args[1] == "build" {
-		baseURL := "https://example.com"
-		if len(os.Args) > 2 {
-			baseURL = os.Args[2]
+	flag.StringVar(&paths.Content, "content", paths.Content, "directory containing posts/, collections/, authors/, static/ and robots.txt")
+	flag.StringVar(&paths.Templates, "templates", paths.Templates, "directory containing the page templates")
+	flag.StringVar(&paths.Out, "out", paths.Out, "output directory for build")
+	flag.StringVar(&site.BasePath, "base-path", site.BasePath, "URL path prefix when the site is served under a sub-path, e.g. /blog")
+	flag.BoolVar(&pages.reload, "reload-templates", false, "re-parse templates when they change on disk (server mode, for development)")
+	flag.Parse()
+	site.BasePath = normalizeBasePath(site.BasePath)
+	args := flag.Args()
+
+	if len(args) > 0 && args[0] == "version" {
+		printVersion()
+		return
+	}
+
+	if len(args) > 0 && args[0] == "validate" {
+		if err := validateSite(); err != nil {
+			log.Fatal(err)
 		}
-		if err := buildStatic(baseURL); err != nil {
+		fmt.Println("Content is valid.")
+		return
+	}
+
+	if len(args) > 0 && args[0] == "new" {
+		if err := runNew(args[1:]); err != nil {
 			log.Fatal(err)
 		}
 		return
 	}
 
-	http.HandleFunc("/", handleIndex)
-	http.HandleFunc("/post/", handlePost)
-	http.HandleFunc("/collections", handleCollections)
-	http.HandleFunc("/collection/", handleCollection)
-	http.HandleFunc("/feed.xml", handleRSS)
-	http.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, "robots.txt")
-	})
-	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
+	if len(args) > 1 && args[0] == "preview-url" {
+		baseURL := site.BaseURL
+		if len(args) > 2 {
+			baseURL = args[2]
+		}
+		url, err := previewURL(args[1], baseURL)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(url)
+		return
+	}
+
+	if len(args) > 0 && args[0] == "check" {
+		flags := flag.NewFlagSet("check", flag.ExitOnError)
+		external := flags.Bool("external", false, "also send a HEAD request to every external URL")
+		timeout := flags.Duration("timeout", 10*time.Second, "per-request timeout for -external")
+		concurrency := flags.Int("concurrency", 8, "maximum parallel requests for -external")
+		flags.Parse(args[1:])
+		if err := runCheck(paths.Out, *external, *timeout, *concurrency); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if len(args) > 0 && args[0] == "serve-dist" {
+		flags := flag.NewFlagSet("serve-dist", flag.ExitOnError)
+		dir := flags.String("dir", paths.Out, "built site to serve")
+		port := flags.String("port", envString("PORT", "8080"), "port to listen on")
+		flags.Parse(args[1:])
+		if err := serveDist(*dir, *port); err != nil {
+			logger.Error("serving build", "err", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(args) > 0 && args[0] == "build" {
+		opts := buildOptions{BaseURL: site.BaseURL, OutDir: paths.Out}
+		flags := flag.NewFlagSet("build", flag.ExitOnError)
+		flags.BoolVar(&opts.Strict, "strict", false, "fail the build on broken internal links, posts in missing collections, post styles or scripts missing from static/, or redirects to missing pages")
+		flags.BoolVar(&opts.ResponsiveImages, "responsive-images", false, "generate resized image variants and srcset attributes (slower)")
+		flags.BoolVar(&opts.OGImages, "og-images", false, "generate a share image for posts without an image")
+		flags.BoolVar(&opts.CriticalCSS, "critical-css", false, "inline static/critical.css into each page and load the full stylesheet asynchronously")
+		flags.BoolVar(&opts.Incremental, "incremental", false, "keep the output directory and re-render only pages whose content, templates or config changed")
+		flags.StringVar(&opts.ImageDir, "image-dir", "", "only resize images under this directory of static/ (co-located post images are always eligible)")
+		verbose := flags.Bool("verbose", false, "list every output file with its size and build time")
+		quiet := flags.Bool("quiet", false, "print errors only")
+		jsonReport := flags.Bool("json", false, "print a JSON description of every output file")
+		flags.BoolVar(&opts.Summary, "report", false, "print a breakdown of the output by kind, with sizes and build time")
+		flags.BoolVar(&site.NoIndex, "no-index", site.NoIndex, "ask search engines not to index the build, for staging deploys")
+		dryRun := flags.Bool("dry-run", false, "list the files the build would add, modify or remove without touching the output directory; exits 2 if there are any")
+		flags.Parse(args[1:])
+		switch {
+		case *jsonReport:
+			opts.Report = reportJSON
+		case *quiet:
+			opts.Report = reportQuiet
+		case *verbose:
+			opts.Report = reportVerbose
+		}
+		if flags.NArg() > 0 {
+			opts.BaseURL = flags.Arg(0)
+		}
+		if *dryRun {
+			changes, err := dryRunBuild(opts)
+			if err != nil {
+				log.Fatal(err)
+			}
+			changes.print(os.Stdout)
+			if !changes.empty() {
+				os.Exit(exitChangesPending)
+			}
+			return
+		}
+		if err := buildStatic(opts); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Parse every template up front so a broken one stops the server at
+	// startup instead of surfacing as a 500 on some page.
+	if err := pages.load(); err != nil {
+		logger.Error("parsing templates", "err", err)
+		os.Exit(1)
+	}
+
+	// Warm the post cache; /healthz reports unhealthy until a load succeeds
+	postCount := 0
+	if posts, err := loadPosts(); err != nil {
+		logger.Error("loading posts", "err", err)
+	} else {
+		postCount = len(posts)
+	}
+
+	route("/healthz", handleHealthz)
+	route("/", handleIndex)
+	route("/post/", handlePost)
+	route("/collections", handleCollections)
+	route("/collections/", handleCollections)
+	route("/collection/", handleCollection)
+	route("/archive", handleArchive)
+	route("/archive/", handleArchive)
+	route("/author/", handleAuthor)
+	route("/feed.xml", handleRSS)
+	route("/feeds.opml", handleOPML)
+	route("/sitemap.xml", handleSitemap)
+	route("/search-index.json", handleSearchIndex)
+	route("/api/posts", withCORS(handleAPIPosts))
+	route("/api/posts.json", withCORS(handleAPIPosts))
+	route("/api/posts/", withCORS(handleAPIPost))
+	route("/api/collections", withCORS(handleAPICollections))
+	route("/api/collections.json", withCORS(handleAPICollections))
+	route("/robots.txt", handleRobots)
+	route("/static/", staticHandler().ServeHTTP)
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
-	fmt.Printf("Server starting on http://localhost:%s\n", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	server := &http.Server{
+		Addr:              ":" + port,
+		Handler:           compressHandler(redirectHandler(http.DefaultServeMux)),
+		ReadHeaderTimeout: site.ReadTimeout,
+		ReadTimeout:       site.ReadTimeout,
+		WriteTimeout:      site.WriteTimeout,
+		IdleTimeout:       site.IdleTimeout,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-ctx.Done()
+		logger.Info("shutting down, waiting for in-flight requests", "timeout", site.ShutdownTimeout)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), site.ShutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Warn("shutdown timed out, closing remaining connections", "err", err)
+			server.Close()
+			return
+		}
+		logger.Info("all requests drained")
+	}()
+
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		logger.Error("listening", "addr", server.Addr, "err", err)
+		os.Exit(1)
+	}
+	mode := "production"
+	if pages.reload {
+		mode = "development"
+	}
+	logger.Info("server started", "addr", listener.Addr().String(), "url", "http://localhost:"+port,
+		"posts", postCount, "mode", mode, "content", paths.Content)
+	if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+		logger.Error("serving", "err", err)
+		os.Exit(1)
+	}
+	<-shutdownDone
+}
+
+// route registers handler for pattern under the configured base path.
+// Handlers see the path with the prefix removed, so they match "/post/" and
+// friends as if the site were served from the root.
+func route(pattern string, handler http.HandlerFunc) {
+	http.Handle(site.BasePath+pattern, http.StripPrefix(site.BasePath, handler))
 }
 
-func buildStatic(baseURL string) error {
-	distDir := "dist"
+// buildOptions controls a static build.
+type buildOptions struct {
+	BaseURL          string
+	OutDir           string
+	Strict           bool
+	ResponsiveImages bool
+	ImageDir         string
+	OGImages         bool
+	CriticalCSS      bool // inline static/critical.css and load the stylesheets without blocking
+	Incremental      bool // keep the previous output and re-render only the pages whose inputs changed
+	Report           reportMode
+	Summary          bool      // print a breakdown of the output by kind
+	BuiltAt          time.Time // build time stamped into pages, the build's start when zero
+}
+
+func buildStatic(opts buildOptions) error {
+	distDir := opts.OutDir
+	baseURL := strings.TrimSuffix(opts.BaseURL, "/")
+	rep := newBuildReport(opts.Report, distDir)
+	rep.summary = opts.Summary
+	if opts.BuiltAt.IsZero() {
+		stampBuild(rep.start)
+	} else {
+		stampBuild(opts.BuiltAt)
+	}
 
-	// Clean and create dist directory
-	os.RemoveAll(distDir)
+	// Clean and create dist directory. An incremental build keeps it when
+	// the previous manifest records what each page was built from.
+	if err := checkOutDir(distDir); err != nil {
+		return err
+	}
+	if opts.Incremental {
+		if manifest, err := readManifest(filepath.Join(distDir, manifestFile)); err == nil && len(manifest.Inputs) > 0 {
+			rep.previous = manifest.Inputs
+		}
+	}
+	if rep.previous == nil {
+		os.RemoveAll(distDir)
+	}
 	os.MkdirAll(distDir, 0755)
 
 	// Load posts and collections
@@ -151,63 +498,289 @@ func buildStatic(baseURL string) error {
 		return err
 	}
 
-	// Build index page
-	fmt.Println("Building index.html...")
-	if err := buildPage(distDir+"/index.html", "templates/layout.html", "templates/index.html",
-		IndexData{Title: "", Posts: posts, PageType: "index"}); err != nil {
+	authors, err := loadAuthors()
+	if err != nil {
 		return err
 	}
 
-	// Build post pages
-	for _, post := range posts {
-		post.PageType = "post"
+	inlineCSS = ""
+	if opts.CriticalCSS {
+		if inlineCSS, err = loadCriticalCSS(); err != nil {
+			return err
+		}
+	}
+	rep.renderKey = renderKey()
+
+	if err := validateContent(posts); err != nil {
+		return err
+	}
+	for _, warning := range append(postWarnings(posts), collectionWarnings(collections)...) {
+		rep.warn(warning)
+	}
+	orphaned := orphanedCollectionRefs(posts, collections)
+	if len(orphaned) > 0 && opts.Strict {
+		return errors.New(strings.Join(orphaned, "\n"))
+	}
+	for _, problem := range orphaned {
+		rep.warn(problem)
+	}
+	missingRefs := missingStaticRefs(posts)
+	if len(missingRefs) > 0 && opts.Strict {
+		return errors.New(strings.Join(missingRefs, "\n"))
+	}
+	for _, problem := range missingRefs {
+		rep.warn(problem)
+	}
+
+	// Build the index page, plus one per additional post language
+	if err := rep.writePage("page", distDir+"/index.html", paths.template("index.html"), paths.template("index.html"), newIndexData(posts, site.Language, baseURL)); err != nil {
+		return err
+	}
+	for _, lang := range otherLanguages(posts) {
+		dir := distDir + "/" + lang
+		os.MkdirAll(dir, 0755)
+		if err := rep.writePage("page", dir+"/index.html", paths.template("index.html"), paths.template("index.html"), newIndexData(posts, lang, baseURL)); err != nil {
+			return err
+		}
+	}
+
+	// Build post pages, copying co-located assets first so image tags
+	// can be rewritten before the page and the feed are rendered
+	var resizer *imageResizer
+	if opts.ResponsiveImages {
+		resizer = newImageResizer(distDir, opts.ImageDir)
+	}
+	for i := range posts {
+		post := &posts[i]
+		source, _ := postSourcePath(post.Slug)
 		dir := distDir + "/post/" + post.Slug
 		os.MkdirAll(dir, 0755)
-		fmt.Printf("Building post/%s/index.html...\n", post.Slug)
-		if err := buildPage(dir+"/index.html", "templates/layout.html", "templates/post.html", post); err != nil {
+		if len(post.Assets) > 0 {
+			if err := copyPostAssets(post, dir); err != nil {
+				return fmt.Errorf("copying assets for %s: %w", source, err)
+			}
+			for _, asset := range post.Assets {
+				rep.record("asset", filepath.Join(dir, filepath.FromSlash(asset)), paths.content("posts", post.Slug, filepath.FromSlash(asset)), 0)
+			}
+		}
+		if resizer != nil {
+			for _, warning := range resizer.rewrite(post) {
+				rep.warn(fmt.Sprintf("post %s: %s", post.Slug, warning))
+			}
+		}
+		if post.Image != "" {
+			if err := measurePostImage(post); err != nil {
+				rep.warn(fmt.Sprintf("post %s: %v", post.Slug, err))
+			}
+			post.ImageURL = absoluteURL(baseURL, post.Image)
+		} else if opts.OGImages {
+			if err := rep.writeKeyed("og-image", dir+"/og.png", source, rep.inputKey("og-image", *post), func() error {
+				return writeOGImage(dir+"/og.png", *post)
+			}); err != nil {
+				return err
+			}
+			post.ImageURL = absoluteURL(baseURL, "/post/"+post.Slug+"/og.png")
+			post.ImageWidth, post.ImageHeight = ogImageWidth, ogImageHeight
+		}
+		page := *post
+		page.PageMeta = newPageMeta("post", baseURL, "/post/"+post.Slug)
+		page.StructuredData = postJSONLD(page, baseURL)
+		page.Content = withBasePath(page.Content)
+		page.Translations = withTranslationURLs(post.Translations, baseURL)
+		if err := rep.writePage("post", dir+"/index.html", source, paths.template(postTemplate(page)), page); err != nil {
 			return err
 		}
 	}
 
+	// Build redirect stubs for old post slugs
+	for _, post := range posts {
+		source, _ := postSourcePath(post.Slug)
+		for _, alias := range post.Aliases {
+			dir := filepath.Join(distDir, "post", alias)
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return err
+			}
+			out := filepath.Join(dir, "index.html")
+			if err := rep.write("redirect", out, source, func() error {
+				return buildRedirectPage(out, canonicalURL(baseURL, "/post/"+post.Slug))
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
 	// Build collections index page
-	fmt.Println("Building collections/index.html...")
 	os.MkdirAll(distDir+"/collections", 0755)
-	if err := buildPage(distDir+"/collections/index.html", "templates/layout.html", "templates/collections.html",
-		CollectionsData{Title: "Collections", Collections: collections, PageType: "collections"}); err != nil {
+	if err := rep.writePage("page", distDir+"/collections/index.html", paths.template("collections.html"), paths.template("collections.html"),
+		CollectionsData{Title: "Collections", Collections: rootCollections(collections), PageMeta: newPageMeta("collections", baseURL, "/collections")}); err != nil {
+		return err
+	}
+
+	// Build the page static hosts serve for missing paths
+	if err := rep.writePage("page", distDir+"/404.html", paths.template("404.html"), paths.template("404.html"), newNotFoundData(baseURL)); err != nil {
+		return err
+	}
+
+	// Build archive page
+	os.MkdirAll(distDir+"/archive", 0755)
+	if err := rep.writePage("page", distDir+"/archive/index.html", paths.template("archive.html"), paths.template("archive.html"), newArchiveData(posts, baseURL)); err != nil {
 		return err
 	}
 
 	// Build individual collection pages
 	for _, collection := range collections {
-		collection.PageType = "collection"
 		dir := distDir + "/collection/" + collection.Slug
+		for n := 1; ; n++ {
+			page, ok := collectionPage(collection, n, baseURL)
+			if !ok {
+				break
+			}
+			pageDir := distDir + pagePath("/collection/"+collection.Slug, n)
+			os.MkdirAll(pageDir, 0755)
+			if err := rep.writePage("collection", pageDir+"/index.html", paths.content("collections", collection.Slug+".html"), paths.template("collection.html"), page); err != nil {
+				return err
+			}
+		}
+		if err := rep.write("feed", dir+"/feed.xml", paths.content("collections", collection.Slug+".html"), func() error {
+			return buildRSSFeed(dir+"/feed.xml", newCollectionFeed(baseURL, collection))
+		}); err != nil {
+			return err
+		}
+	}
+
+	// Build author pages
+	for _, author := range authors {
+		author.PageMeta = newPageMeta("author", baseURL, "/author/"+author.Slug)
+		author.Posts = authorPosts(author.Slug, posts)
+		dir := distDir + "/author/" + author.Slug
 		os.MkdirAll(dir, 0755)
-		fmt.Printf("Building collection/%s/index.html...\n", collection.Slug)
-		if err := buildPage(dir+"/index.html", "templates/layout.html", "templates/collection.html", collection); err != nil {
+		if err := rep.writePage("author", dir+"/index.html", paths.content("authors", author.Slug+".html"), paths.template("author.html"), author); err != nil {
 			return err
 		}
 	}
 
 	// Build RSS feed
-	fmt.Println("Building feed.xml...")
-	if err := buildRSSFeed(distDir+"/feed.xml", baseURL, posts); err != nil {
+	if err := rep.write("feed", distDir+"/feed.xml", "", func() error {
+		return buildRSSFeed(distDir+"/feed.xml", newRSSFeed(baseURL, posts))
+	}); err != nil {
+		return err
+	}
+	if err := rep.write("feed", distDir+"/feeds.opml", "", func() error {
+		return buildOPML(distDir+"/feeds.opml", baseURL, collections)
+	}); err != nil {
+		return err
+	}
+
+	if err := rep.write("sitemap", distDir+"/sitemap.xml", "", func() error {
+		return buildSitemap(distDir+"/sitemap.xml", baseURL, posts, collections, authors)
+	}); err != nil {
+		return err
+	}
+
+	// Build the JSON API
+	if err := buildAPI(rep, distDir, baseURL, posts, collections); err != nil {
+		return err
+	}
+
+	// Build search index
+	if err := rep.write("search", distDir+"/search-index.json", "", func() error {
+		return buildSearchIndex(distDir+"/search-index.json", posts)
+	}); err != nil {
 		return err
 	}
 
 	// Copy static assets
-	fmt.Println("Copying static assets...")
-	if err := copyDir("static", distDir+"/static"); err != nil {
+	staticDir := paths.content("static")
+	err = filepath.WalkDir(staticDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(staticDir, path)
+		dst := filepath.Join(distDir, "static", rel)
+		if d.IsDir() {
+			return os.MkdirAll(dst, 0755)
+		}
+		return rep.write("static", dst, path, func() error { return copyFile(path, dst) })
+	})
+	if err != nil {
 		return err
 	}
 
-	// Copy robots.txt
-	if _, err := os.Stat("robots.txt"); err == nil {
-		fmt.Println("Copying robots.txt...")
-		copyFile("robots.txt", distDir+"/robots.txt")
+	// Copy robots.txt, or generate one if the site has none
+	if _, err := os.Stat(paths.content("robots.txt")); err == nil {
+		if err := rep.write("static", distDir+"/robots.txt", paths.content("robots.txt"), func() error {
+			return copyFile(paths.content("robots.txt"), distDir+"/robots.txt")
+		}); err != nil {
+			return err
+		}
+	} else if err := rep.write("robots", distDir+"/robots.txt", "", func() error {
+		return buildRobots(distDir+"/robots.txt", baseURL)
+	}); err != nil {
+		return err
 	}
 
-	fmt.Println("Build complete! Output in ./dist")
-	return nil
+	// Drop what earlier builds wrote and this one did not, before the
+	// redirects and the link check look at what the output holds
+	if rep.previous != nil {
+		if err := rep.removeStale(); err != nil {
+			return err
+		}
+	}
+
+	// Write refresh pages for redirects.txt, now that the targets exist
+	missing, err := buildRedirects(rep, distDir, baseURL)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 && opts.Strict {
+		return errors.New(strings.Join(missing, "\n"))
+	}
+	for _, problem := range missing {
+		rep.warn(problem)
+	}
+
+	// Check internal links
+	broken, err := checkLinks(distDir)
+	if err != nil {
+		return err
+	}
+	for _, problem := range broken {
+		rep.brokenLink(problem)
+	}
+	if len(broken) > 0 && opts.Strict {
+		return fmt.Errorf("found %d broken internal links", len(broken))
+	}
+
+	// Record what the build produced, for deploy tooling
+	rep.recordGenerated()
+	manifest := newBuildManifest(rep.files, posts, collections, baseURL, rep.start)
+	manifest.Inputs = rep.inputs
+	if err := rep.write("manifest", distDir+"/"+manifestFile, "", func() error {
+		return writeManifest(distDir+"/"+manifestFile, manifest)
+	}); err != nil {
+		return err
+	}
+
+	return rep.finish()
+}
+
+// checkOutDir refuses to let a build wipe a directory that does not look
+// like an earlier build, such as a misconfigured -out pointing at the
+// content itself. Missing and empty directories are fine.
+func checkOutDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && len(entries) == 0) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, marker := range []string{manifestFile, "index.html"} {
+		if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("refusing to replace %s: it holds files but no index.html or %s from an earlier build; remove it or choose another -out", dir, manifestFile)
 }
 
 func buildPage(outputPath, layoutPath, contentPath string, data interface{}) error {
@@ -223,36 +796,173 @@ func buildPage(outputPath, layoutPath, contentPath string, data interface{}) err
 	return tmpl.ExecuteTemplate(f, "layout", data)
 }
 
-func buildRSSFeed(outputPath, baseURL string, posts []Post) error {
-	var items []Item
+// validateSite loads all content and runs the same checks as the build.
+func validateSite() error {
+	posts, err := loadPosts()
+	if err != nil {
+		return err
+	}
+	collections, err := loadCollections()
+	if err != nil {
+		return err
+	}
+	if _, err := loadAuthors(); err != nil {
+		return err
+	}
+	if _, err := loadRedirects(); err != nil {
+		return err
+	}
+	ignored, err := ignoredPostFiles()
+	if err != nil {
+		return err
+	}
+	for _, path := range ignored {
+		fmt.Printf("warning: %s is not a post and is ignored (nested post directories are not supported)\n", path)
+	}
+	printWarnings(posts)
+	for _, warning := range append(orphanedCollectionRefs(posts, collections), collectionWarnings(collections)...) {
+		fmt.Println("warning: " + warning)
+	}
+	for _, warning := range missingStaticRefs(posts) {
+		fmt.Println("warning: " + warning)
+	}
+	return validateContent(posts)
+}
+
+// printWarnings reports non-fatal problems found while loading posts.
+func printWarnings(posts []Post) {
+	for _, warning := range postWarnings(posts) {
+		fmt.Println("warning: " + warning)
+	}
+}
+
+func postWarnings(posts []Post) []string {
+	var warnings []string
 	for _, post := range posts {
-		pubDate := ""
-		if t, err := time.Parse("2006-01-02", post.RawDate); err == nil {
-			pubDate = t.Format(time.RFC1123Z)
+		for _, warning := range post.Warnings {
+			warnings = append(warnings, fmt.Sprintf("post %s: %s", post.Slug, warning))
+		}
+	}
+	if featured, _ := splitPinned(posts); len(featured) > site.MaxPinned {
+		warnings = append(warnings, fmt.Sprintf("%d posts are pinned, more than the maximum of %d", len(featured), site.MaxPinned))
+	}
+	return warnings
+}
+
+// sortForIndex orders posts for the index according to INDEX_SORT. The
+// default "published" keeps loadPosts' publish-date order; "updated" puts the
+// most recently revised posts first. Feeds always use publish order.
+func sortForIndex(posts []Post) []Post {
+	if site.IndexSort != "updated" {
+		return posts
+	}
+	sorted := append([]Post(nil), posts...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return lastUpdated(sorted[i]).After(lastUpdated(sorted[j]))
+	})
+	return sorted
+}
+
+// lastUpdated is when a post last changed: its updated date, falling back to
+// the publish date.
+func lastUpdated(post Post) time.Time {
+	if !post.UpdatedAt.IsZero() {
+		return post.UpdatedAt
+	}
+	return post.Published
+}
+
+// splitPinned separates pinned posts from the rest, preserving order, so the
+// index can feature them without listing anything twice. Feeds, archives and
+// collection pages ignore pinning and use the unsplit list.
+func splitPinned(posts []Post) (pinned, rest []Post) {
+	for _, post := range posts {
+		if post.Pinned {
+			pinned = append(pinned, post)
+		} else {
+			rest = append(rest, post)
 		}
-		description := string(post.Description)
-		if description == "" {
-			description = string(post.Content)
+	}
+	return pinned, rest
+}
+
+// validateContent runs the cross-post consistency checks that must pass
+// before a build is published.
+func validateContent(posts []Post) error {
+	return errors.Join(
+		validateAliases(posts),
+		validateAuthors(posts),
+		validateUpdated(posts),
+		validateTranslations(posts),
+	)
+}
+
+// validateUpdated reports posts whose updated date precedes their publish
+// date, which is almost always a typo in one of the two.
+func validateUpdated(posts []Post) error {
+	var errs []error
+	for _, post := range posts {
+		if !post.UpdatedAt.IsZero() && post.UpdatedAt.Before(post.Published) {
+			errs = append(errs, fmt.Errorf("post %q is updated %s, before it was published %s", post.Slug, post.RawUpdated, post.RawDate))
 		}
-		items = append(items, Item{
-			Title:       post.Title,
-			Link:        fmt.Sprintf("%s/post/%s", baseURL, post.Slug),
-			Description: description,
-			PubDate:     pubDate,
-			GUID:        fmt.Sprintf("%s/post/%s", baseURL, post.Slug),
-		})
 	}
+	return errors.Join(errs...)
+}
 
-	feed := RSS{
-		Version: "2.0",
-		Channel: &Channel{
-			Title:       "BreakLab",
-			Link:        baseURL,
-			Description: "Blog posts from BreakLab",
-			Items:       items,
-		},
+var redirectTemplate = template.Must(template.New("redirect").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Redirecting…</title>
+    <link rel="canonical" href="{{.}}">
+    <meta name="robots" content="noindex">
+    <meta http-equiv="refresh" content="0; url={{.}}">
+</head>
+<body>
+    <p>This page has moved to <a href="{{.}}">{{.}}</a>.</p>
+</body>
+</html>
+`))
+
+func buildRedirectPage(outputPath, target string) error {
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return redirectTemplate.Execute(f, target)
+}
+
+// slugRegex matches a single URL path segment of lowercase letters, digits
+// and hyphens, the shape of every slug a build writes to disk.
+var slugRegex = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// validateAliases ensures every alias is a plain slug, maps to exactly one
+// post and never shadows a real post slug.
+func validateAliases(posts []Post) error {
+	slugs := make(map[string]bool, len(posts))
+	for _, post := range posts {
+		slugs[post.Slug] = true
 	}
+	owners := make(map[string]string)
+	for _, post := range posts {
+		for _, alias := range post.Aliases {
+			if !slugRegex.MatchString(alias) {
+				return fmt.Errorf("alias %q on post %q is not a slug: use only a-z, 0-9 and -", alias, post.Slug)
+			}
+			if slugs[alias] {
+				return fmt.Errorf("alias %q on post %q collides with an existing post slug", alias, post.Slug)
+			}
+			if owner, ok := owners[alias]; ok && owner != post.Slug {
+				return fmt.Errorf("alias %q is declared by both %q and %q", alias, owner, post.Slug)
+			}
+			owners[alias] = post.Slug
+		}
+	}
+	return nil
+}
 
+func buildRSSFeed(outputPath string, feed RSS) error {
 	f, err := os.Create(outputPath)
 	if err != nil {
 		return err
@@ -265,119 +975,371 @@ func buildRSSFeed(outputPath, baseURL string, posts []Post) error {
 	return encoder.Encode(feed)
 }
 
-func copyDir(src, dst string) error {
-	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		relPath, _ := filepath.Rel(src, path)
-		dstPath := filepath.Join(dst, relPath)
-		if info.IsDir() {
-			return os.MkdirAll(dstPath, 0755)
-		}
-		return copyFile(path, dstPath)
-	})
+// newRSSFeed lists posts in the site language; translations only appear on
+// their own language's index.
+func newRSSFeed(baseURL string, posts []Post) RSS {
+	return RSS{
+		Version:   "2.0",
+		DCNS:      "http://purl.org/dc/elements/1.1/",
+		ContentNS: feedContentNS(),
+		Channel: &Channel{
+			Title:       site.Title,
+			Link:        canonicalURL(baseURL, "/"),
+			Description: site.Description,
+			Language:    site.Language,
+			Items:       rssItems(baseURL, inLanguage(posts, site.Language)),
+		},
+	}
+}
+
+// newCollectionFeed lists a collection's posts in reading order.
+func newCollectionFeed(baseURL string, collection Collection) RSS {
+	description := collection.DescriptionText
+	if description == "" {
+		description = site.Description
+	}
+	return RSS{
+		Version:   "2.0",
+		DCNS:      "http://purl.org/dc/elements/1.1/",
+		ContentNS: feedContentNS(),
+		Channel: &Channel{
+			Title:       collection.Title + " – " + site.Title,
+			Link:        canonicalURL(baseURL, "/collection/"+collection.Slug),
+			Description: description,
+			Language:    site.Language,
+			Items:       rssItems(baseURL, collection.Posts),
+		},
+	}
+}
+
+// collectionFeedLink advertises a collection's own feed on its page.
+func collectionFeedLink(baseURL string, collection Collection) FeedLink {
+	return FeedLink{Title: collection.Title + " – " + site.Title, URL: canonicalURL(baseURL, "/collection/"+collection.Slug+"/feed.xml")}
+}
+
+// rssItems describes posts for a feed. With FEED_MODE=summary (the
+// default) an item carries the post's description or excerpt and a "Read
+// more" link; with FEED_MODE=full it carries the whole post as well.
+func rssItems(baseURL string, posts []Post) []Item {
+	var items []Item
+	for _, post := range posts {
+		pubDate := ""
+		if !post.Published.IsZero() {
+			pubDate = post.Published.Format(time.RFC1123Z)
+		}
+		link := canonicalURL(baseURL, "/post/"+post.Slug)
+
+		item := Item{
+			Title:       post.Title,
+			Link:        link,
+			Description: post.ExcerptText,
+			Creator:     post.AuthorName,
+			PubDate:     pubDate,
+			GUID:        link,
+		}
+		if site.FeedMode == "full" {
+			item.Content = transformOutsideCode(string(post.Content), func(s string) string {
+				return absolutizeURLs(s, baseURL+site.BasePath)
+			})
+		} else {
+			item.Description = fmt.Sprintf(`<p>%s</p><p><a href="%s">Read more</a></p>`, template.HTMLEscapeString(post.ExcerptText), template.HTMLEscapeString(link))
+		}
+		items = append(items, item)
+	}
+
+	return items
+}
+
+func feedContentNS() string {
+	if site.FeedMode == "full" {
+		return "http://purl.org/rss/1.0/modules/content/"
+	}
+	return ""
+}
+
+func copyFile(src, dst string) error {
+	input, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, input, 0644)
+}
+
+// handleHealthz reports whether the server is up and has loaded its posts.
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if !postCache.ready() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("posts not loaded\n"))
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
+
+func handleIndex(w http.ResponseWriter, r *http.Request) {
+	posts, err := loadPosts()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Besides "/", this serves /<lang> for every additional post language
+	lang := site.Language
+	if r.URL.Path != "/" {
+		lang = strings.Trim(r.URL.Path, "/")
+		if !slices.Contains(otherLanguages(posts), lang) {
+			http.NotFound(w, r)
+			return
+		}
+		if redirectTrailingSlash(w, r) {
+			return
+		}
+	}
+
+	pages.render(w, "index", newIndexData(posts, lang, requestBaseURL(r)))
+}
+
+// newIndexData builds the index for one language: the site language at "/"
+// and any other at "/<lang>".
+func newIndexData(posts []Post, lang, baseURL string) IndexData {
+	path := "/"
+	if lang != site.Language {
+		path = "/" + lang
+	}
+	featured, rest := splitPinned(sortForIndex(inLanguage(posts, lang)))
+	return IndexData{Title: site.Title, Lang: lang, Featured: featured, Posts: rest, PageMeta: newPageMeta("index", baseURL, path)}
+}
+
+func handlePost(w http.ResponseWriter, r *http.Request) {
+	if redirectTrailingSlash(w, r) {
+		return
+	}
+	slug := strings.TrimPrefix(r.URL.Path, "/post/")
+	if slug == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if postSlug, asset, ok := strings.Cut(slug, "/"); ok {
+		servePostAsset(w, r, postSlug, asset)
+		return
+	}
+
+	post, err := loadPost(slug)
+	if err != nil {
+		if target, ok := findAliasTarget(slug); ok {
+			http.Redirect(w, r, sitePath("/post/"+target), http.StatusMovedPermanently)
+			return
+		}
+		http.NotFound(w, r)
+		return
+	}
+	if !isPublished(post) {
+		if !validPreview(r, slug) {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("X-Robots-Tag", "noindex")
+	}
+	post.PageMeta = newPageMeta("post", requestBaseURL(r), "/post/"+post.Slug)
+	post.StructuredData = postJSONLD(post, requestBaseURL(r))
+	post.Content = withBasePath(post.Content)
+	if all, err := postCache.get(); err == nil {
+		post.Translations = withTranslationURLs(translationsFor(post, all), requestBaseURL(r))
+	}
+	if post.Image != "" {
+		post.ImageURL = absoluteURL(requestBaseURL(r), post.Image)
+	}
+
+	pages.renderPost(w, r, post)
+}
+
+// requestBaseURL derives the site's base URL from the incoming request,
+// honoring X-Forwarded-Proto when running behind a TLS-terminating proxy.
+// Requests for the configured site host, with or without "www.", and over
+// either scheme get SITE_BASE_URL itself, so canonical links and feeds name
+// one address however the page was reached. Other hosts, such as localhost
+// during development, keep their own.
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	proto, _, _ := strings.Cut(r.Header.Get("X-Forwarded-Proto"), ",")
+	if r.TLS != nil || strings.TrimSpace(proto) == "https" {
+		scheme = "https"
+	}
+	if configured, err := url.Parse(site.BaseURL); err == nil && configured.Host != "" && sameSiteHost(r.Host, configured.Host) {
+		return site.BaseURL
+	}
+	return fmt.Sprintf("%s://%s", scheme, r.Host)
+}
+
+// sameSiteHost compares two hosts ignoring case and a leading "www.".
+func sameSiteHost(a, b string) bool {
+	return strings.TrimPrefix(strings.ToLower(a), "www.") == strings.TrimPrefix(strings.ToLower(b), "www.")
+}
+
+// canonicalURL joins baseURL, the base path and path into the absolute
+// canonical form used across pages and feeds: no trailing slash except for
+// the site root.
+func canonicalURL(baseURL, path string) string {
+	path = "/" + strings.Trim(path, "/")
+	if path == "/" {
+		return strings.TrimSuffix(baseURL, "/") + sitePath("/")
+	}
+	return strings.TrimSuffix(baseURL, "/") + sitePath(path)
+}
+
+// sitePath prefixes a root-relative path with the configured base path.
+func sitePath(path string) string {
+	return site.BasePath + path
 }
 
-func copyFile(src, dst string) error {
-	input, err := os.ReadFile(src)
-	if err != nil {
-		return err
+// redirectTrailingSlash sends a 301 from "/post/slug/" to "/post/slug" so
+// each page has a single canonical address matching the URLs used in feeds
+// and templates. Section roots such as "/post/" are left alone so they 404
+// rather than bouncing to a path no handler serves. It reports whether it
+// redirected.
+func redirectTrailingSlash(w http.ResponseWriter, r *http.Request) bool {
+	if !strings.HasSuffix(r.URL.Path, "/") || r.URL.Path == "/" {
+		return false
 	}
-	return os.WriteFile(dst, input, 0644)
+	switch r.URL.Path {
+	case "/post/", "/collection/", "/author/":
+		return false
+	}
+	target := sitePath(strings.TrimRight(r.URL.Path, "/"))
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, target, http.StatusMovedPermanently)
+	return true
 }
 
-func handleIndex(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path != "/" {
+// servePostAsset serves a file stored next to a directory-layout post.
+func servePostAsset(w http.ResponseWriter, r *http.Request, slug, asset string) {
+	if strings.HasSuffix(asset, ".html") || strings.Contains(asset, "..") {
 		http.NotFound(w, r)
 		return
 	}
-
-	posts, err := loadPosts()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	post, err := loadPost(slug)
+	if err != nil || !isPublished(post) {
+		http.NotFound(w, r)
 		return
 	}
+	http.ServeFile(w, r, paths.content("posts", slug, filepath.FromSlash(asset)))
+}
 
-	tmpl, err := parseTemplates("templates/layout.html", "templates/index.html")
+// findAliasTarget returns the slug of the post that declares alias, if any.
+func findAliasTarget(alias string) (string, bool) {
+	posts, err := loadPosts()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return "", false
 	}
-
-	data := IndexData{Title: "", Posts: posts, PageType: "index"}
-	if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	for _, post := range posts {
+		for _, a := range post.Aliases {
+			if a == alias {
+				return post.Slug, true
+			}
+		}
 	}
+	return "", false
 }
 
-func handlePost(w http.ResponseWriter, r *http.Request) {
-	slug := strings.TrimPrefix(r.URL.Path, "/post/")
-	if slug == "" {
-		http.NotFound(w, r)
+func handleCollections(w http.ResponseWriter, r *http.Request) {
+	if redirectTrailingSlash(w, r) {
 		return
 	}
-
-	post, err := loadPost(slug)
-	if err != nil {
+	if r.URL.Path != "/collections" {
 		http.NotFound(w, r)
 		return
 	}
-	post.PageType = "post"
 
-	tmpl, err := parseTemplates("templates/layout.html", "templates/post.html")
+	collections, err := loadCollections()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	if err := tmpl.ExecuteTemplate(w, "layout", post); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	data := CollectionsData{Title: "Collections", Collections: rootCollections(collections), PageMeta: newPageMeta("collections", requestBaseURL(r), "/collections")}
+	pages.render(w, "collections", data)
 }
 
-func handleCollections(w http.ResponseWriter, r *http.Request) {
-	collections, err := loadCollections()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+func handleCollection(w http.ResponseWriter, r *http.Request) {
+	if redirectTrailingSlash(w, r) {
+		return
+	}
+	slug := strings.TrimPrefix(r.URL.Path, "/collection/")
+	slug, isFeed := strings.CutSuffix(slug, "/feed.xml")
+	// Later pages are at /collection/<slug>/page/<n>, like the static
+	// build, or /collection/<slug>?page=<n>
+	n := 1
+	pageNumber := r.URL.Query().Get("page")
+	if before, after, ok := strings.Cut(slug, "/page/"); ok && !isFeed {
+		slug, pageNumber = before, after
+	}
+	if pageNumber != "" {
+		var err error
+		if n, err = strconv.Atoi(pageNumber); err != nil {
+			http.NotFound(w, r)
+			return
+		}
+	}
+	if slug == "" {
+		http.NotFound(w, r)
 		return
 	}
 
-	tmpl, err := parseTemplates("templates/layout.html", "templates/collections.html")
+	// Load the whole tree so the page knows its parent and sub-collections
+	collections, err := loadCollections()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	data := CollectionsData{Title: "Collections", Collections: collections, PageType: "collections"}
-	if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	var collection Collection
+	for _, c := range collections {
+		if c.Slug == slug {
+			collection = c
+		}
+	}
+	if collection.Slug == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if isFeed {
+		serveRSS(w, r, collection.Posts, func() RSS { return newCollectionFeed(requestBaseURL(r), collection) })
+		return
 	}
+	page, ok := collectionPage(collection, n, requestBaseURL(r))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	pages.render(w, "collection", page)
 }
 
-func handleCollection(w http.ResponseWriter, r *http.Request) {
-	slug := strings.TrimPrefix(r.URL.Path, "/collection/")
+func handleAuthor(w http.ResponseWriter, r *http.Request) {
+	if redirectTrailingSlash(w, r) {
+		return
+	}
+	slug := strings.TrimPrefix(r.URL.Path, "/author/")
 	if slug == "" {
 		http.NotFound(w, r)
 		return
 	}
 
-	collection, err := loadCollection(slug)
+	author, err := loadAuthor(slug)
 	if err != nil {
 		http.NotFound(w, r)
 		return
 	}
-	collection.PageType = "collection"
-
-	tmpl, err := parseTemplates("templates/layout.html", "templates/collection.html")
+	posts, err := loadPosts()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	author.Posts = authorPosts(slug, posts)
+	author.PageMeta = newPageMeta("author", requestBaseURL(r), "/author/"+author.Slug)
 
-	if err := tmpl.ExecuteTemplate(w, "layout", collection); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	pages.render(w, "author", author)
 }
 
 func handleRSS(w http.ResponseWriter, r *http.Request) {
@@ -387,58 +1349,13 @@ func handleRSS(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get the base URL from the request
-	scheme := "http"
-	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
-		scheme = "https"
-	}
-	baseURL := fmt.Sprintf("%s://%s", scheme, r.Host)
-
-	var items []Item
-	for _, post := range posts {
-		// Parse date and convert to RFC822 format for RSS
-		pubDate := ""
-		if t, err := time.Parse("2006-01-02", post.RawDate); err == nil {
-			pubDate = t.Format(time.RFC1123Z)
-		}
-
-		description := string(post.Description)
-		if description == "" {
-			description = string(post.Content)
-		}
-
-		items = append(items, Item{
-			Title:       post.Title,
-			Link:        fmt.Sprintf("%s/post/%s", baseURL, post.Slug),
-			Description: description,
-			PubDate:     pubDate,
-			GUID:        fmt.Sprintf("%s/post/%s", baseURL, post.Slug),
-		})
-	}
-
-	feed := RSS{
-		Version: "2.0",
-		Channel: &Channel{
-			Title:       "BreakLab",
-			Link:        baseURL,
-			Description: "Blog posts from BreakLab",
-			Items:       items,
-		},
-	}
-
-	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
-	w.Write([]byte(xml.Header))
-	encoder := xml.NewEncoder(w)
-	encoder.Indent("", "  ")
-	if err := encoder.Encode(feed); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	serveRSS(w, r, posts, func() RSS { return newRSSFeed(requestBaseURL(r), posts) })
 }
 
 func loadCollections() ([]Collection, error) {
 	var collections []Collection
 
-	err := filepath.WalkDir("collections", func(path string, d fs.DirEntry, err error) error {
+	err := filepath.WalkDir(paths.content("collections"), func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
@@ -459,31 +1376,117 @@ func loadCollections() ([]Collection, error) {
 		return nil, err
 	}
 
-	// Sort by title
-	sort.Slice(collections, func(i, j int) bool {
-		return collections[i].Title < collections[j].Title
-	})
+	return arrangeCollections(collections)
+}
+
+// arrangeCollections orders collections as a tree: every parent is followed
+// by its sub-collections, and siblings are sorted with complete collections
+// after the others, each group by title. Each collection
+// also carries its children so templates can render the tree from the roots.
+// A parent that does not exist, or a parent chain that loops, is an error.
+func arrangeCollections(collections []Collection) ([]Collection, error) {
+	bySlug := make(map[string]Collection)
+	for _, c := range collections {
+		bySlug[c.Slug] = c
+	}
+
+	children := make(map[string][]string)
+	for _, c := range collections {
+		chain := []string{c.Slug}
+		seen := map[string]bool{c.Slug: true}
+		for parent := c.Parent; parent != ""; parent = bySlug[parent].Parent {
+			if _, ok := bySlug[parent]; !ok {
+				return nil, fmt.Errorf("collection %q has unknown parent %q", chain[len(chain)-1], parent)
+			}
+			chain = append(chain, parent)
+			if seen[parent] {
+				return nil, fmt.Errorf("collection parent cycle: %s", strings.Join(chain, " -> "))
+			}
+			seen[parent] = true
+		}
+		children[c.Parent] = append(children[c.Parent], c.Slug)
+	}
+	for _, slugs := range children {
+		sort.Slice(slugs, func(i, j int) bool {
+			a, b := bySlug[slugs[i]], bySlug[slugs[j]]
+			if aDone, bDone := a.Status == "complete", b.Status == "complete"; aDone != bDone {
+				return bDone
+			}
+			return a.Title < b.Title
+		})
+	}
 
-	return collections, nil
+	var ordered []Collection
+	var build func(slug string) Collection
+	build = func(slug string) Collection {
+		c := bySlug[slug]
+		c.ParentTitle = bySlug[c.Parent].Title
+		i := len(ordered)
+		ordered = append(ordered, c)
+		for _, child := range children[slug] {
+			c.Children = append(c.Children, build(child))
+		}
+		ordered[i] = c
+		return c
+	}
+	for _, root := range children[""] {
+		build(root)
+	}
+	return ordered, nil
+}
+
+// rootCollections returns the top level of an arranged collection list.
+func rootCollections(collections []Collection) []Collection {
+	var roots []Collection
+	for _, c := range collections {
+		if c.Parent == "" {
+			roots = append(roots, c)
+		}
+	}
+	return roots
 }
 
+// loadPosts returns published posts, newest first. Drafts and posts dated in
+// the future are left out; handlePost can still serve them as previews.
 func loadPosts() ([]Post, error) {
-	var posts []Post
+	all, err := postCache.get()
+	if err != nil {
+		return nil, err
+	}
+	var published []Post
+	for _, post := range all {
+		if isPublished(post) {
+			published = append(published, post)
+		}
+	}
+	return published, nil
+}
+
+// loadAllPosts reads every post under posts/, including drafts and
+// scheduled posts, newest first.
+func loadAllPosts() ([]Post, error) {
+	var all []Post
+	sources := make(map[string]string)
 
-	err := filepath.WalkDir("posts", func(path string, d fs.DirEntry, err error) error {
+	err := filepath.WalkDir(paths.content("posts"), func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
-		if d.IsDir() || !strings.HasSuffix(path, ".html") {
+		slug, ok := postSlugFromPath(path)
+		if d.IsDir() || !ok {
 			return nil
 		}
+		// posts/foo.html and posts/foo/index.html would both be /post/foo.
+		if other, dup := sources[slug]; dup {
+			return fmt.Errorf("duplicate post slug %q: %s and %s", slug, other, path)
+		}
+		sources[slug] = path
 
-		slug := strings.TrimSuffix(filepath.Base(path), ".html")
 		post, err := loadPost(slug)
 		if err != nil {
 			return err
 		}
-		posts = append(posts, post)
+		all = append(all, post)
 		return nil
 	})
 
@@ -491,27 +1494,35 @@ func loadPosts() ([]Post, error) {
 		return nil, err
 	}
 
-	sort.Slice(posts, func(i, j int) bool {
-		return posts[i].RawDate > posts[j].RawDate
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Published.After(all[j].Published)
 	})
+	for i := range all {
+		all[i].Translations = translationsFor(all[i], all)
+	}
 
-	return posts, nil
+	return all, nil
 }
 
 func loadCollection(slug string) (Collection, error) {
-	content, err := os.ReadFile(filepath.Join("collections", slug+".html"))
+	content, err := os.ReadFile(paths.content("collections", slug+".html"))
 	if err != nil {
 		return Collection{}, err
 	}
 
-	lines := strings.Split(string(content), "\n")
-	description := strings.TrimSpace(extractContent(lines))
+	meta, body := parseFrontMatter(string(content))
+	description := strings.TrimSpace(body)
 
+	planned, status, warnings := parseCollectionProgress(meta)
 	collection := Collection{
 		Slug:            slug,
-		Title:           extractMeta(lines, "title"),
+		Title:           meta["title"],
 		Description:     template.HTML(description),
 		DescriptionText: stripHTML(description),
+		Parent:          meta["parent"],
+		Planned:         planned,
+		Status:          status,
+		Warnings:        warnings,
 	}
 
 	// Load all posts and filter by collection
@@ -520,42 +1531,157 @@ func loadCollection(slug string) (Collection, error) {
 		return Collection{}, err
 	}
 
+	ordered := false
 	for _, post := range posts {
 		if post.Collection == slug {
 			collection.Posts = append(collection.Posts, post)
+			ordered = ordered || post.Order > 0
+		}
+	}
+	// Collections that use `order` are listed in reading order; others keep
+	// the newest-first listing
+	if ordered {
+		sort.SliceStable(collection.Posts, func(i, j int) bool {
+			return memberOf(collection.Posts[i]).before(memberOf(collection.Posts[j]))
+		})
+	}
+
+	collection.PostCount = len(collection.Posts)
+	// Sum the per-post figures, so the total agrees with the posts listed.
+	for _, post := range collection.Posts {
+		collection.TotalWordCount += post.WordCount
+		collection.TotalReadTimeMinutes += post.ReadTimeInMinutes
+	}
+
+	switch {
+	case collection.Planned > 0:
+		if len(collection.Posts) > collection.Planned {
+			collection.Warnings = append(collection.Warnings, fmt.Sprintf("planned is %d but %d posts are published", collection.Planned, len(collection.Posts)))
 		}
+		collection.PercentComplete = min(100, 100*len(collection.Posts)/collection.Planned)
+	case collection.Status == "complete":
+		collection.PercentComplete = 100
 	}
 
 	return collection, nil
 }
 
-func getCollectionPosition(currentSlug, collectionSlug, currentDate string) (int, int) {
-	type postInfo struct {
-		slug string
-		date string
+// parseCollectionProgress reads the optional `planned` and `status`
+// metadata of a collection. Bad values are reported and otherwise ignored.
+func parseCollectionProgress(meta map[string]string) (planned int, status string, warnings []string) {
+	if value := meta["planned"]; value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			warnings = append(warnings, fmt.Sprintf("planned %q is not a positive number", value))
+		} else {
+			planned = n
+		}
+	}
+	switch value := strings.ToLower(meta["status"]); value {
+	case "", "complete", "ongoing", "paused":
+		status = value
+	default:
+		warnings = append(warnings, fmt.Sprintf("status %q is not complete, ongoing or paused", meta["status"]))
+	}
+	return planned, status, warnings
+}
+
+func collectionWarnings(collections []Collection) []string {
+	var warnings []string
+	for _, c := range collections {
+		for _, warning := range c.Warnings {
+			warnings = append(warnings, fmt.Sprintf("collection %s: %s", c.Slug, warning))
+		}
+		// an umbrella collection may hold only sub-collections
+		if len(c.Posts) == 0 && len(c.Children) == 0 {
+			warnings = append(warnings, fmt.Sprintf("collection %s: has no published posts", c.Slug))
+		}
+	}
+	return warnings
+}
+
+// orphanedCollectionRefs lists posts whose collection metadata names no
+// file under collections/. Such a post renders, but links to a collection
+// page that does not exist.
+func orphanedCollectionRefs(posts []Post, collections []Collection) []string {
+	known := make(map[string]bool, len(collections))
+	for _, c := range collections {
+		known[c.Slug] = true
+	}
+	var problems []string
+	for _, post := range posts {
+		if post.Collection != "" && !known[post.Collection] {
+			problems = append(problems, fmt.Sprintf("post %s: collection %q does not exist (no collections/%s.html)", post.Slug, post.Collection, post.Collection))
+		}
+	}
+	return problems
+}
+
+// collectionMember is the part of a post that decides its place in a
+// collection.
+type collectionMember struct {
+	slug  string
+	order int
+	date  time.Time
+}
+
+func memberOf(post Post) collectionMember {
+	return collectionMember{slug: post.Slug, order: post.Order, date: post.Published}
+}
+
+// before orders collection members for reading: posts with an explicit
+// order come first, ascending, followed by the rest oldest first. The slug
+// breaks ties so the result never depends on walk order.
+func (a collectionMember) before(b collectionMember) bool {
+	switch {
+	case (a.order > 0) != (b.order > 0):
+		return a.order > 0
+	case a.order != b.order:
+		return a.order < b.order
+	case !a.date.Equal(b.date):
+		return a.date.Before(b.date)
+	}
+	return a.slug < b.slug
+}
+
+// parseOrder reads an `order` value, a positive integer.
+func parseOrder(value string) (int, error) {
+	if value == "" {
+		return 0, nil
 	}
-	var postsInCollection []postInfo
+	order, err := strconv.Atoi(value)
+	if err != nil || order < 1 {
+		return 0, fmt.Errorf("order %q is not a positive integer", value)
+	}
+	return order, nil
+}
 
-	filepath.WalkDir("posts", func(path string, d fs.DirEntry, err error) error {
-		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".html") {
+func getCollectionPosition(currentSlug, collectionSlug string) (int, int) {
+	var postsInCollection []collectionMember
+
+	filepath.WalkDir(paths.content("posts"), func(path string, d fs.DirEntry, err error) error {
+		slug, ok := postSlugFromPath(path)
+		if err != nil || d.IsDir() || !ok {
 			return nil
 		}
 		content, err := os.ReadFile(path)
 		if err != nil {
 			return nil
 		}
-		lines := strings.Split(string(content), "\n")
-		if extractMeta(lines, "collection") == collectionSlug {
-			slug := strings.TrimSuffix(filepath.Base(path), ".html")
-			date := extractMeta(lines, "date")
-			postsInCollection = append(postsInCollection, postInfo{slug: slug, date: date})
+		meta, _ := parseFrontMatter(string(content))
+		date, _ := parsePostDate(meta["date"])
+		if isHidden(meta["draft"] == "true", date) {
+			return nil
+		}
+		if meta["collection"] == collectionSlug {
+			order, _ := parseOrder(meta["order"])
+			postsInCollection = append(postsInCollection, collectionMember{slug: slug, order: order, date: date})
 		}
 		return nil
 	})
 
-	// Sort by date ascending (oldest first)
 	sort.Slice(postsInCollection, func(i, j int) bool {
-		return postsInCollection[i].date < postsInCollection[j].date
+		return postsInCollection[i].before(postsInCollection[j])
 	})
 
 	total := len(postsInCollection)
@@ -570,105 +1696,338 @@ func getCollectionPosition(currentSlug, collectionSlug, currentDate string) (int
 	return index, total
 }
 
-func stripHTML(s string) string {
-	re := regexp.MustCompile(`<[^>]*>`)
-	text := re.ReplaceAllString(s, "")
-	// Collapse whitespace
-	text = regexp.MustCompile(`\s+`).ReplaceAllString(text, " ")
-	return strings.TrimSpace(text)
+// postSlugFromPath maps a file under posts/ to its slug. A post is either a
+// single posts/<slug>.html file or a directory posts/<slug>/ holding an
+// index.html plus co-located assets; any other HTML file is not a post.
+// Slugs are flat: deeper directories are not supported as a way to group
+// posts (use collections), and `validate` warns about HTML files they hold.
+func postSlugFromPath(path string) (string, bool) {
+	rel, err := filepath.Rel(paths.content("posts"), path)
+	if err != nil || !strings.HasSuffix(rel, ".html") {
+		return "", false
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	switch {
+	case len(parts) == 1:
+		return strings.TrimSuffix(parts[0], ".html"), true
+	case len(parts) == 2 && parts[1] == "index.html":
+		return parts[0], true
+	}
+	return "", false
+}
+
+// ignoredPostFiles lists HTML files under posts/ that postSlugFromPath does
+// not recognise, such as posts/2024/foo.html.
+func ignoredPostFiles() ([]string, error) {
+	var ignored []string
+	err := filepath.WalkDir(paths.content("posts"), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if _, ok := postSlugFromPath(path); !d.IsDir() && !ok && strings.HasSuffix(path, ".html") {
+			ignored = append(ignored, path)
+		}
+		return nil
+	})
+	return ignored, err
+}
+
+// postSourcePath returns the source file for slug and whether the post uses
+// the directory layout.
+func postSourcePath(slug string) (string, bool) {
+	file := paths.content("posts", slug+".html")
+	if _, err := os.Stat(file); err == nil {
+		return file, false
+	}
+	return paths.content("posts", slug, "index.html"), true
+}
+
+// parsePostDate reads a `date` value, either a full RFC3339 timestamp or a
+// bare 2006-01-02 date taken as midnight in the site timezone. A missing date
+// means the post was published now.
+func parsePostDate(value string) (time.Time, error) {
+	if value == "" {
+		return now(), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, site.Location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("date %q is neither 2006-01-02 nor RFC3339", value)
+	}
+	return t, nil
 }
 
 func loadPost(slug string) (Post, error) {
-	content, err := os.ReadFile(filepath.Join("posts", slug+".html"))
+	sourcePath, isDir := postSourcePath(slug)
+	content, err := os.ReadFile(sourcePath)
 	if err != nil {
 		return Post{}, err
 	}
 
-	lines := strings.Split(string(content), "\n")
-	rawContent := extractContent(lines)
+	meta, rawContent := parseFrontMatter(string(content))
+	if rawContent, err = expandIncludes(rawContent); err != nil {
+		return Post{}, fmt.Errorf("%s: %w", sourcePath, err)
+	}
+	// Sanitize the body as written, before anything below adds markup of
+	// its own, so headings get their ids and links their rel once clean.
+	var sanitized []string
+	if site.Sanitize {
+		rawContent, sanitized = sanitizeHTML(rawContent)
+	}
+	var assets []string
+	if isDir {
+		if assets, err = postAssets(filepath.Dir(sourcePath)); err != nil {
+			return Post{}, err
+		}
+		rawContent = rewriteAssetURLs(rawContent, slug)
+	}
+
+	rawContent = renderMath(rawContent)
+	rawContent = expandEmoji(rawContent)
 
 	// Process content to add IDs to headings and extract TOC
 	processedContent, toc := processContentWithTOC(rawContent)
+	// Headings keep their ids either way, so in-page links still work.
+	showTOC := meta["toc"] != "false" && tocLen(toc) >= site.TOCMinHeadings
+	if !showTOC {
+		toc = nil
+	}
+	warnings := sanitized
+	processedContent = processLinks(processedContent, site.BaseURL)
+	processedContent, unusedNotes := processFootnotes(processedContent, slug)
+	for _, name := range unusedNotes {
+		warnings = append(warnings, fmt.Sprintf("footnote [^%s] is defined but never referenced", name))
+	}
+	warnings = append(warnings, missingAltWarnings(sourcePath, string(content))...)
+	if meta["eager-images"] != "true" {
+		processedContent = addLoadingHints(processedContent)
+	}
+	if site.ImageCaptions {
+		processedContent = wrapFigures(processedContent)
+	}
+	if site.CodeCopyButtons {
+		processedContent = wrapCodeBlocks(processedContent)
+	}
+
+	extraStyles, problems := staticRefs("styles", meta["styles"])
+	warnings = append(warnings, problems...)
+	extraScripts, problems := staticRefs("scripts", meta["scripts"])
+	warnings = append(warnings, problems...)
+
+	if meta["title"] == "" {
+		warnings = append(warnings, "no title metadata, using the slug")
+	}
+	if meta["date"] == "" {
+		warnings = append(warnings, "no date metadata, treating the post as published now")
+	}
+	published, err := parsePostDate(meta["date"])
+	rawDate, formattedDate := meta["date"], meta["date"]
+	if err != nil {
+		warnings = append(warnings, err.Error())
+	} else {
+		local := published.In(site.Location)
+		rawDate, formattedDate = local.Format("2006-01-02"), local.Format("January 2, 2006")
+	}
+
+	var updatedAt time.Time
+	var rawUpdated, formattedUpdated string
+	if meta["updated"] != "" {
+		if updatedAt, err = parsePostDate(meta["updated"]); err != nil {
+			warnings = append(warnings, "updated "+err.Error())
+		} else {
+			local := updatedAt.In(site.Location)
+			rawUpdated, formattedUpdated = local.Format("2006-01-02"), local.Format("January 2, 2006")
+		}
+	}
+
+	lang := meta["lang"]
+	if lang == "" {
+		lang = site.Language
+	}
+
+	order, err := parseOrder(meta["order"])
+	if err != nil {
+		warnings = append(warnings, err.Error())
+	}
 
-	rawDate := extractMeta(lines, "date")
-	if rawDate == "" {
-		rawDate = time.Now().Format("2006-01-02")
+	var layout string
+	value := meta["layout"]
+	if value == "" {
+		value = meta["template"]
 	}
-	formattedDate := rawDate
-	if t, err := time.Parse("2006-01-02", rawDate); err == nil {
-		formattedDate = t.Format("January 2, 2006")
+	if value != "" {
+		if layout, err = parseLayoutName(value); err != nil {
+			warnings = append(warnings, err.Error())
+		} else if _, err := os.Stat(paths.template(layout + ".html")); err != nil {
+			warnings = append(warnings, fmt.Sprintf("layout template %s.html not found, using post.html", layout))
+		}
 	}
 
-	collectionSlug := extractMeta(lines, "collection")
+	collectionSlug := meta["collection"]
 	var collectionTitle string
 	var collectionDescription template.HTML
-	var collectionIndex, collectionTotal int
+	var collectionIndex, collectionTotal, collectionPlanned int
+	var collectionStatus string
 	if collectionSlug != "" {
-		if collectionContent, err := os.ReadFile(filepath.Join("collections", collectionSlug+".html")); err == nil {
-			collectionLines := strings.Split(string(collectionContent), "\n")
-			collectionTitle = extractMeta(collectionLines, "title")
-			collectionDescription = template.HTML(strings.TrimSpace(extractContent(collectionLines)))
+		if collectionContent, err := os.ReadFile(paths.content("collections", collectionSlug+".html")); err == nil {
+			collectionMeta, collectionBody := parseFrontMatter(string(collectionContent))
+			collectionTitle = collectionMeta["title"]
+			collectionDescription = template.HTML(strings.TrimSpace(collectionBody))
+			// bad values are reported once, by the collection itself
+			collectionPlanned, collectionStatus, _ = parseCollectionProgress(collectionMeta)
 		}
 		// Calculate position in collection
-		collectionIndex, collectionTotal = getCollectionPosition(slug, collectionSlug, rawDate)
+		collectionIndex, collectionTotal = getCollectionPosition(slug, collectionSlug)
+	}
+
+	authorSlugs := splitList(meta["author"])
+	if len(authorSlugs) == 0 {
+		authorSlugs = []string{site.Author}
+	}
+	var authors []Author
+	for _, authorSlug := range authorSlugs {
+		authors = append(authors, resolveAuthor(authorSlug))
 	}
 
 	post := Post{
 		Slug:                  slug,
-		Title:                 extractMeta(lines, "title"),
-		Description:           template.HTML(extractMeta(lines, "description")),
+		Meta:                  meta,
+		Title:                 expandShortcodes(meta["title"]),
+		Author:                authorSlugs[0],
+		AuthorName:            authors[0].Name,
+		AuthorBio:             authors[0].Bio,
+		Authors:               authors,
+		Description:           template.HTML(meta["description"]),
 		Date:                  formattedDate,
 		RawDate:               rawDate,
+		Published:             published,
+		Updated:               formattedUpdated,
+		RawUpdated:            rawUpdated,
+		UpdatedAt:             updatedAt,
 		Collection:            collectionSlug,
+		Tags:                  splitList(meta["tags"]),
 		CollectionTitle:       collectionTitle,
 		CollectionDescription: collectionDescription,
 		CollectionIndex:       collectionIndex,
 		CollectionTotal:       collectionTotal,
+		CollectionPlanned:     collectionPlanned,
+		CollectionStatus:      collectionStatus,
 		Content:               template.HTML(processedContent),
 		TOC:                   toc,
+		ShowTOC:               showTOC,
+		Aliases:               splitList(meta["aliases"]),
+		Layout:                layout,
+		Draft:                 meta["draft"] == "true",
+		Pinned:                meta["pinned"] == "true",
+		Order:                 order,
+		Lang:                  lang,
+		TranslationOf:         meta["translation-of"],
+		Assets:                assets,
+		ExtraStyles:           extraStyles,
+		ExtraScripts:          extraScripts,
+		Image:                 resolveImageURL(meta["image"], slug, isDir),
+		Warnings:              warnings,
 	}
 	if post.Title == "" {
 		post.Title = slug
 	}
+	post.Excerpt, post.ExcerptText = buildExcerpt(post.Description, string(post.Content), site.ExcerptLength)
+
+	prose := withoutHeadingAnchors(string(post.Content))
+	post.WordCount = proseWords(prose)
+	minutes := readingMinutes(prose, site.WordsPerMinute)
+
+	// round up so a 350-word post reads as 2 minutes, never reporting less than 1
+	post.ReadTimeInMinutes = int(math.Max(math.Ceil(minutes), 1.0))
+	if minutes < 1 {
+		post.ReadTimeLabel = "less than a minute"
+	} else {
+		post.ReadTimeLabel = fmt.Sprintf("%d min read", post.ReadTimeInMinutes)
+	}
+
+	return post, nil
+}
 
-	words := len(strings.Fields(string(post.Content)))
+var paragraphRegex = regexp.MustCompile(`(?is)<p\b[^>]*>(.*?)</p>`)
 
-	// compute the reading time in minutes assuming 200 words / min
-	// cap at 1 minute reading time
-	post.ReadTimeInMinutes = int(math.Max(float64(words)/200, 1.0))
+// buildExcerpt returns an HTML-safe and a plain-text summary of a post. An
+// explicit description wins; otherwise the first paragraph that contains
+// text (skipping image-only paragraphs and code) is stripped of markup and
+// cut at a word boundary near length characters.
+func buildExcerpt(description template.HTML, content string, length int) (template.HTML, string) {
+	if description != "" {
+		return description, stripHTML(string(description))
+	}
+	for _, m := range paragraphRegex.FindAllStringSubmatch(codeRegex.ReplaceAllString(content, ""), -1) {
+		if text := stripHTML(m[1]); text != "" {
+			if len(text) > length {
+				text = truncateText(text, length) + "…"
+			}
+			return template.HTML(template.HTMLEscapeString(text)), text
+		}
+	}
+	return "", ""
+}
 
-	return post, nil
+// codeLinesPerMinute is how many lines of a code listing a reader skims per
+// minute; code is far denser than prose, so it is not counted word by word.
+const codeLinesPerMinute = 20
+
+// readingMinutes estimates the unrounded reading time of rendered HTML:
+// prose is counted from the stripped text at wpm, while <pre>/<code> contents
+// are excluded from the word count and charged per line instead.
+func readingMinutes(content string, wpm int) float64 {
+	codeLines := 0
+	for _, block := range codeRegex.FindAllString(content, -1) {
+		// Trim inside the tags, so a listing ending in a newline before
+		// </code> is not charged an extra line.
+		codeLines += strings.Count(strings.TrimSpace(tagRegex.ReplaceAllString(block, "")), "\n") + 1
+	}
+	return float64(proseWords(content))/float64(wpm) + float64(codeLines)/codeLinesPerMinute
 }
 
-func extractMeta(lines []string, key string) string {
-	prefix := "<!-- " + key + ": "
-	for _, line := range lines {
-		if strings.HasPrefix(line, prefix) {
-			return strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(line, prefix), " -->"))
+// proseWords counts the words of rendered HTML outside <pre>/<code>.
+func proseWords(content string) int {
+	return countWords(codeRegex.ReplaceAllString(content, " "))
+}
+
+var metaCommentRegex = regexp.MustCompile(`(?s)^<!--\s*([A-Za-z][\w-]*)\s*:(.*?)-->`)
+
+// parseFrontMatter splits a source file into its metadata and body. Metadata
+// is the run of `<!-- key: value -->` comments at the top of the file, with
+// blank lines allowed between them; a value may span several lines up to the
+// closing -->, and its whitespace is collapsed to single spaces. Keys are
+// case-insensitive and stored lower-cased, and spacing inside the comment is
+// optional, so `<!--Title:X-->` reads like `<!-- title: X -->`. The body is
+// everything after the last metadata comment, so comments inside the article
+// are never mistaken for metadata. An include directive ends the metadata
+// even at the top of the file, since it is content.
+func parseFrontMatter(source string) (map[string]string, string) {
+	meta := make(map[string]string)
+	rest := source
+	for {
+		trimmed := strings.TrimLeft(rest, " \t\r\n")
+		m := metaCommentRegex.FindStringSubmatch(trimmed)
+		if m == nil || strings.EqualFold(m[1], "include") {
+			break
 		}
+		meta[strings.ToLower(m[1])] = strings.Join(strings.Fields(m[2]), " ")
+		rest = trimmed[len(m[0]):]
 	}
-	return ""
+	return meta, rest
 }
 
-func extractContent(lines []string) string {
-	var contentLines []string
-	metaKeys := []string{"title:", "date:", "description:", "collection:"}
-	for _, line := range lines {
-		if strings.HasPrefix(line, "<!--") {
-			isMeta := false
-			for _, key := range metaKeys {
-				if strings.Contains(line, key) {
-					isMeta = true
-					break
-				}
-			}
-			if isMeta {
-				continue
-			}
+// splitList parses a comma-separated metadata value, dropping empty entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
 		}
-		contentLines = append(contentLines, line)
 	}
-	return strings.Join(contentLines, "\n")
+	return items
 }
 
 func generateID(text string) string {
@@ -680,9 +2039,11 @@ func generateID(text string) string {
 	text = strings.ToLower(text)
 	text = strings.TrimSpace(text)
 
-	// Replace non-alphanumeric characters with hyphens
-	re = regexp.MustCompile(`[^a-z0-9]+`)
-	text = re.ReplaceAllString(text, "-")
+	// Replace runs of anything but Unicode letters and digits with hyphens,
+	// so "Über Caching" keeps its Ü and non-Latin headings stay meaningful
+	text = strings.Join(strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), "-")
 
 	// Remove leading/trailing hyphens
 	text = strings.Trim(text, "-")
@@ -690,28 +2051,115 @@ func generateID(text string) string {
 	return text
 }
 
+var (
+	headingRegex = regexp.MustCompile(`(?s)<h([2-4])(\s[^>]*)?>(.*?)</h([2-4])>`)
+	idAttrRegex  = regexp.MustCompile(`\bid\s*=\s*["']([^"']*)["']`)
+	codeRegex    = regexp.MustCompile(`(?is)<pre\b.*?</pre>|<code\b.*?</code>`)
+)
+
+// transformOutsideCode applies fn to every part of content that is not inside
+// a <pre> or <code> element, leaving code samples byte-for-byte intact.
+func transformOutsideCode(content string, fn func(string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range codeRegex.FindAllStringIndex(content, -1) {
+		b.WriteString(fn(content[last:loc[0]]))
+		b.WriteString(content[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	b.WriteString(fn(content[last:]))
+	return b.String()
+}
+
+// processContentWithTOC assigns an id to every h2-h4 heading outside code
+// blocks, appends a permalink anchor inside it, and returns the headings as
+// a nested outline in document order. Existing attributes, including an
+// explicit id, are preserved. Each heading records how many prose words
+// precede it, counted like readingMinutes counts them, so code is excluded.
 func processContentWithTOC(content string) (string, []TOCItem) {
-	var toc []TOCItem
-
-	// Match h2 and h3 tags
-	h2Regex := regexp.MustCompile(`<h2>(.*?)</h2>`)
-	h3Regex := regexp.MustCompile(`<h3>(.*?)</h3>`)
-
-	// Process h2 tags
-	content = h2Regex.ReplaceAllStringFunc(content, func(match string) string {
-		text := h2Regex.FindStringSubmatch(match)[1]
-		id := generateID(text)
-		toc = append(toc, TOCItem{ID: id, Text: text, Level: 2})
-		return fmt.Sprintf(`<h2 id="%s">%s</h2>`, id, text)
+	var headings []TOCItem
+	words := 0
+
+	content = transformOutsideCode(content, func(s string) string {
+		var b strings.Builder
+		last := 0
+		for _, loc := range headingRegex.FindAllStringIndex(s, -1) {
+			words += countWords(s[last:loc[0]])
+			b.WriteString(s[last:loc[0]])
+			b.WriteString(processHeading(s[loc[0]:loc[1]], &headings, words))
+			last = loc[1]
+		}
+		words += countWords(s[last:])
+		b.WriteString(s[last:])
+		return b.String()
 	})
 
-	// Process h3 tags
-	content = h3Regex.ReplaceAllStringFunc(content, func(match string) string {
-		text := h3Regex.FindStringSubmatch(match)[1]
-		id := generateID(text)
-		toc = append(toc, TOCItem{ID: id, Text: text, Level: 3})
-		return fmt.Sprintf(`<h3 id="%s">%s</h3>`, id, text)
-	})
+	return content, nestTOC(headings)
+}
+
+// processHeading gives one heading its id and permalink anchor and appends
+// it to headings. Mismatched tags such as <h2>…</h3> are left alone.
+func processHeading(match string, headings *[]TOCItem, wordOffset int) string {
+	m := headingRegex.FindStringSubmatch(match)
+	level, attrs, text := m[1], m[2], m[3]
+	if level != m[4] {
+		return match
+	}
 
-	return content, toc
+	var id string
+	if existing := idAttrRegex.FindStringSubmatch(attrs); existing != nil {
+		id = existing[1]
+	} else {
+		id = generateID(text)
+		if id == "" {
+			// e.g. a heading made only of symbols or an image
+			id = fmt.Sprintf("section-%d", len(*headings)+1)
+		}
+		attrs = fmt.Sprintf(` id="%s"`, id) + attrs
+	}
+
+	*headings = append(*headings, TOCItem{ID: id, Text: text, Level: int(level[0] - '0'), WordOffset: wordOffset})
+	if !site.HeadingAnchors {
+		return fmt.Sprintf(`<h%s%s>%s</h%s>`, level, attrs, text, level)
+	}
+	return fmt.Sprintf(`<h%s%s>%s<a class="anchor" href="#%s" aria-hidden="true">#</a></h%s>`, level, attrs, text, id, level)
+}
+
+var headingAnchorRegex = regexp.MustCompile(`<a class="anchor" href="#[^"]*" aria-hidden="true">#</a>`)
+
+// withoutHeadingAnchors removes the permalinks processHeading adds, for
+// text that should only hold what the author wrote, such as word counts.
+func withoutHeadingAnchors(content string) string {
+	return headingAnchorRegex.ReplaceAllString(content, "")
+}
+
+func countWords(html string) int {
+	return len(strings.Fields(stripHTML(html)))
+}
+
+// tocLen counts the headings in a nested TOC.
+func tocLen(items []TOCItem) int {
+	n := len(items)
+	for _, item := range items {
+		n += tocLen(item.Children)
+	}
+	return n
+}
+
+// nestTOC turns a flat list of headings into a tree where each heading holds
+// the deeper headings that follow it as Children.
+func nestTOC(headings []TOCItem) []TOCItem {
+	var roots []TOCItem
+	for len(headings) > 0 {
+		item := headings[0]
+		headings = headings[1:]
+		end := 0
+		for end < len(headings) && headings[end].Level > item.Level {
+			end++
+		}
+		item.Children = nestTOC(headings[:end])
+		headings = headings[end:]
+		roots = append(roots, item)
+	}
+	return roots
 }
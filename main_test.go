@@ -0,0 +1,272 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestSite lays out a content directory holding files, keyed by path
+// relative to it, and points paths at it and at the repo's templates. The
+// previous paths, config and caches come back when the test ends.
+func newTestSite(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	templates, err := filepath.Abs("templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	savedPaths, savedSite, savedPosts, savedFeeds := paths, site, postCache, feedsCache
+	t.Cleanup(func() {
+		paths, site, postCache, feedsCache = savedPaths, savedSite, savedPosts, savedFeeds
+	})
+	paths = sitePaths{Content: dir, Templates: templates, Out: filepath.Join(dir, "dist")}
+	postCache, feedsCache = &contentCache{}, &feedCache{}
+
+	for _, stylesheet := range []string{"static/css/index.css", "static/css/post.css"} {
+		if _, ok := files[stylesheet]; !ok {
+			files[stylesheet] = "body {}\n"
+		}
+	}
+	// Sites may leave these empty, but not out.
+	for _, sub := range []string{"posts", "collections", "authors"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range files {
+		writeTestFile(t, filepath.Join(dir, filepath.FromSlash(name)), content)
+	}
+	return dir
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testPost is the source of a post with the given title and date.
+func testPost(title, date, body string) string {
+	return "<!-- title: " + title + " -->\n<!-- date: " + date + " -->\n" + body + "\n"
+}
+
+// buildTestSite runs a quiet static build of the current test site.
+func buildTestSite(t *testing.T, opts buildOptions) error {
+	t.Helper()
+	if opts.OutDir == "" {
+		opts.OutDir = paths.Out
+	}
+	if opts.BaseURL == "" {
+		opts.BaseURL = "https://example.com"
+	}
+	opts.Report = reportQuiet
+	return buildStatic(opts)
+}
+
+func TestReadingMinutes(t *testing.T) {
+	words := func(n int, wrap string) string {
+		var b strings.Builder
+		for i := 0; i < n; i++ {
+			b.WriteString(fmt.Sprintf(wrap, "word") + " ")
+		}
+		return b.String()
+	}
+	code := func(lines int) string {
+		return "<pre><code>" + strings.Repeat("x := f(a, b, c, d, e)\n", lines) + "</code></pre>"
+	}
+	tests := []struct {
+		name    string
+		content string
+		want    float64
+	}{
+		{"empty", "", 0},
+		{"plain", "<p>" + words(200, "%s") + "</p>", 1},
+		{"markup heavy", `<div class="a"><p>` + words(200, `<a href="/post/x" class="link external" data-id="1"><em>%s</em></a>`) + "</p></div>", 1},
+		{"comments are not words", "<p>" + words(100, "%s") + "<!-- a b c d e f --></p>", 0.5},
+		{"entities decode within words", "<p>" + words(99, "%s") + " caf&eacute;</p>", 0.5},
+		{"code heavy", "<p>" + words(100, "%s") + "</p>" + code(40), 0.5 + 40.0/codeLinesPerMinute},
+		{"code words are not prose", "<p>" + words(100, "%s") + "</p>" + "<pre>" + strings.Repeat("a b c d e f g h i j\n", 20) + "</pre>", 0.5 + 20.0/codeLinesPerMinute},
+		{"inline code is one line", "<p>" + words(100, "%s") + " <code>a b c d e f g</code></p>", 0.5 + 1.0/codeLinesPerMinute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := readingMinutes(tt.content, 200); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("readingMinutes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadTimeRoundsUp(t *testing.T) {
+	words := func(n int) string { return "<p>" + strings.Repeat("word ", n) + "</p>" }
+	newTestSite(t, map[string]string{
+		"posts/short.html": testPost("Short", "2024-01-01", words(50)),
+		"posts/one.html":   testPost("One", "2024-01-01", words(200)),
+		"posts/mid.html":   testPost("Mid", "2024-01-01", words(350)),
+	})
+	site.WordsPerMinute = 200
+	tests := []struct {
+		slug    string
+		minutes int
+		label   string
+	}{
+		{"short", 1, "less than a minute"},
+		{"one", 1, "1 min read"},
+		{"mid", 2, "2 min read"},
+	}
+	for _, tt := range tests {
+		post, err := loadPost(tt.slug)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if post.ReadTimeInMinutes != tt.minutes || post.ReadTimeLabel != tt.label {
+			t.Errorf("%s: read time %d %q, want %d %q", tt.slug, post.ReadTimeInMinutes, post.ReadTimeLabel, tt.minutes, tt.label)
+		}
+	}
+}
+
+func TestGenerateID(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Hello World", "hello-world"},
+		// Apostrophes split words like any other punctuation, so slugs and
+		// heading anchors already published keep their URLs.
+		{"Don't Panic", "don-t-panic"},
+		{"What’s new in Go 1.22?", "what-s-new-in-go-1-22"},
+		{"HTTP/2 and TLS", "http-2-and-tls"},
+		{"REST APIs", "rest-apis"},
+		{"C++ & C#", "c-c"},
+		{"  --Trimmed--  ", "trimmed"},
+		{"<em>Styled</em> Title", "styled-title"},
+		{"Über Caching", "über-caching"},
+		{"naïve café", "naïve-café"},
+		{"Ελληνικά Λέξεις", "ελληνικά-λέξεις"},
+		{"日本語の見出し", "日本語の見出し"},
+		{"?!", ""},
+	}
+	for _, tt := range tests {
+		if got := generateID(tt.in); got != tt.want {
+			t.Errorf("generateID(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFormatSlug(t *testing.T) {
+	formatSlug := templateFuncs["formatSlug"].(func(string) string)
+	tests := []struct {
+		in, want string
+	}{
+		{"web-dev", "Web Dev"},
+		{"dont_panic", "Dont Panic"},
+		{"don't-panic", "Don't Panic"},
+		{"HTTP-and-TLS", "HTTP And TLS"},
+		{"go_API-design", "Go API Design"},
+		{"über-caching", "Über Caching"},
+		{"ελληνικά-λέξεις", "Ελληνικά Λέξεις"},
+	}
+	for _, tt := range tests {
+		if got := formatSlug(tt.in); got != tt.want {
+			t.Errorf("formatSlug(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMissingCollectionIsReported(t *testing.T) {
+	newTestSite(t, map[string]string{
+		"posts/stray.html":       "<!-- collection: ghost -->\n" + testPost("Stray", "2024-01-01", "<p>x</p>"),
+		"posts/member.html":      "<!-- collection: real -->\n" + testPost("Member", "2024-01-02", "<p>x</p>"),
+		"collections/real.html":  "<!-- title: Real -->\n<p>A real collection.</p>\n",
+		"collections/empty.html": "<!-- title: Empty -->\n<p>Nothing here yet.</p>\n",
+	})
+	posts, err := loadPosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	collections, err := loadCollections()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `post stray: collection "ghost" does not exist (no collections/ghost.html)`
+	if got := orphanedCollectionRefs(posts, collections); len(got) != 1 || got[0] != want {
+		t.Errorf("orphanedCollectionRefs() = %q, want [%q]", got, want)
+	}
+	if got := collectionWarnings(collections); len(got) != 1 || got[0] != "collection empty: has no published posts" {
+		t.Errorf("collectionWarnings() = %q, want only the empty collection", got)
+	}
+
+	if err := buildTestSite(t, buildOptions{}); err != nil {
+		t.Errorf("build failed on a missing collection without -strict: %v", err)
+	}
+	if err := buildTestSite(t, buildOptions{Strict: true}); err == nil || err.Error() != want {
+		t.Errorf("strict build error = %v, want %q", err, want)
+	}
+}
+
+func TestValidateAliases(t *testing.T) {
+	tests := []struct {
+		name  string
+		posts []Post
+		want  string
+	}{
+		{"distinct", []Post{{Slug: "a", Aliases: []string{"old-a", "older-a"}}, {Slug: "b", Aliases: []string{"old-b"}}}, ""},
+		{"same post twice", []Post{{Slug: "a", Aliases: []string{"old-a", "old-a"}}}, ""},
+		{"shadows a slug", []Post{{Slug: "a", Aliases: []string{"b"}}, {Slug: "b"}}, `alias "b" on post "a" collides with an existing post slug`},
+		{"two owners", []Post{{Slug: "a", Aliases: []string{"old"}}, {Slug: "b", Aliases: []string{"old"}}}, `alias "old" is declared by both "a" and "b"`},
+		{"parent dir", []Post{{Slug: "a", Aliases: []string{"../../etc"}}}, `alias "../../etc" on post "a" is not a slug: use only a-z, 0-9 and -`},
+		{"dot dot", []Post{{Slug: "a", Aliases: []string{".."}}}, `alias ".." on post "a" is not a slug: use only a-z, 0-9 and -`},
+		{"nested", []Post{{Slug: "a", Aliases: []string{"2023/a"}}}, `alias "2023/a" on post "a" is not a slug: use only a-z, 0-9 and -`},
+		{"backslash", []Post{{Slug: "a", Aliases: []string{`old\a`}}}, `alias "old\\a" on post "a" is not a slug: use only a-z, 0-9 and -`},
+		{"empty", []Post{{Slug: "a", Aliases: []string{""}}}, `alias "" on post "a" is not a slug: use only a-z, 0-9 and -`},
+		{"uppercase", []Post{{Slug: "a", Aliases: []string{"Old-A"}}}, `alias "Old-A" on post "a" is not a slug: use only a-z, 0-9 and -`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAliases(tt.posts)
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("validateAliases = %v, want nil", err)
+			case tt.want != "" && (err == nil || err.Error() != tt.want):
+				t.Errorf("validateAliases = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildWritesAliasRedirects(t *testing.T) {
+	dir := newTestSite(t, map[string]string{
+		"posts/new.html": "<!-- aliases: old, older -->\n" + testPost("New", "2024-01-01", "<p>x</p>"),
+	})
+	if err := buildTestSite(t, buildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, alias := range []string{"old", "older"} {
+		page, err := os.ReadFile(filepath.Join(paths.Out, "post", alias, "index.html"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(page), `url=https://example.com/post/new`) {
+			t.Errorf("post/%s redirect does not point at the post:\n%s", alias, page)
+		}
+	}
+
+	writeTestFile(t, filepath.Join(dir, "posts", "new.html"), "<!-- aliases: ../../escaped -->\n"+testPost("New", "2024-01-01", "<p>x</p>"))
+	postCache = &contentCache{}
+	if err := buildTestSite(t, buildOptions{}); err == nil || !strings.Contains(err.Error(), `alias "../../escaped" on post "new" is not a slug`) {
+		t.Errorf("build with a traversing alias: err = %v, want the alias rejected", err)
+	}
+	if _, err := os.Stat(filepath.Join(paths.Out, "..", "escaped")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("the alias escaped the output directory: %v", err)
+	}
+}
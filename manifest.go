@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// manifestFile is written at the root of every build. It describes the
+// build rather than the site, so dry runs leave it out of the comparison.
+const manifestFile = "build-manifest.json"
+
+// buildManifest is the machine-readable record of a build, for deploy
+// tooling and for diffing one build against another.
+type buildManifest struct {
+	BuiltAt     time.Time      `json:"built_at"`
+	Version     string         `json:"version"`
+	Commit      string         `json:"commit"`
+	BaseURL     string         `json:"base_url"`
+	Posts       int            `json:"posts"`
+	Collections int            `json:"collections"`
+	Tags        int            `json:"tags"`
+	Pages       []manifestPage `json:"pages"`
+
+	// Inputs maps each page and share image to a hash of what it was
+	// rendered from, so an -incremental build can tell which to redo.
+	Inputs map[string]string `json:"inputs,omitempty"`
+}
+
+type manifestPage struct {
+	Path string `json:"path"`
+	Kind string `json:"kind"`
+	Slug string `json:"slug,omitempty"` // the post, collection or author the page was built from
+}
+
+// newBuildManifest summarizes the recorded output files of a build. Every
+// HTML file is a page; other outputs such as feeds and static files are
+// left to the directory listing.
+func newBuildManifest(files []reportFile, posts []Post, collections []Collection, baseURL string, builtAt time.Time) buildManifest {
+	tags := make(map[string]bool)
+	for _, post := range posts {
+		for _, tag := range post.Tags {
+			tags[strings.ToLower(tag)] = true
+		}
+	}
+	manifest := buildManifest{
+		BuiltAt:     builtAt.UTC().Truncate(time.Second),
+		Version:     currentBuild.Version,
+		Commit:      currentBuild.Commit,
+		BaseURL:     baseURL,
+		Posts:       len(posts),
+		Collections: len(collections),
+		Tags:        len(tags),
+		Pages:       []manifestPage{},
+	}
+	for _, f := range files {
+		if strings.HasSuffix(f.Path, ".html") {
+			manifest.Pages = append(manifest.Pages, manifestPage{Path: f.Path, Kind: f.Kind, Slug: sourceSlug(f.Kind, f.Source)})
+		}
+	}
+	return manifest
+}
+
+// sourceSlug names the content a page was built from: the post for posts
+// and their redirect stubs, the file name for collections and authors.
+func sourceSlug(kind, source string) string {
+	if slug, ok := postSlugFromPath(source); ok {
+		return slug
+	}
+	if kind == "collection" || kind == "author" {
+		return strings.TrimSuffix(filepath.Base(source), ".html")
+	}
+	return ""
+}
+
+func writeManifest(outputPath string, manifest buildManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(outputPath, append(data, '\n'), 0644)
+}
+
+// readManifest loads the manifest of an earlier build.
+func readManifest(path string) (buildManifest, error) {
+	var manifest buildManifest
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return manifest, err
+	}
+	err = json.Unmarshal(data, &manifest)
+	return manifest, err
+}
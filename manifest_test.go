@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewBuildManifest(t *testing.T) {
+	postSource := paths.content("posts", "a.html")
+	files := []reportFile{
+		{Path: "index.html", Kind: "page"},
+		{Path: "post/a/index.html", Kind: "post", Source: postSource},
+		{Path: "post/old-a/index.html", Kind: "redirect", Source: postSource},
+		{Path: "collection/go/index.html", Kind: "collection", Source: "content/collections/go.html"},
+		{Path: "author/sam/index.html", Kind: "author", Source: "content/authors/sam.html"},
+		{Path: "feed.xml", Kind: "feed"},
+		{Path: "static/css/post.css", Kind: "static", Source: "content/static/css/post.css"},
+	}
+	posts := []Post{{Slug: "a", Tags: []string{"Go", "web"}}, {Slug: "b", Tags: []string{"go"}}}
+	collections := []Collection{{Slug: "go"}}
+	builtAt := time.Date(2024, 5, 1, 12, 30, 45, 999, time.FixedZone("x", 3600))
+
+	m := newBuildManifest(files, posts, collections, "https://example.com", builtAt)
+
+	if m.Posts != 2 || m.Collections != 1 || m.Tags != 2 || m.BaseURL != "https://example.com" {
+		t.Errorf("counts = %d posts, %d collections, %d tags, base %q; want 2, 1, 2, https://example.com", m.Posts, m.Collections, m.Tags, m.BaseURL)
+	}
+	if want := time.Date(2024, 5, 1, 11, 30, 45, 0, time.UTC); !m.BuiltAt.Equal(want) || m.BuiltAt.Location() != time.UTC {
+		t.Errorf("BuiltAt = %v, want %v", m.BuiltAt, want)
+	}
+	want := []manifestPage{
+		{Path: "index.html", Kind: "page"},
+		{Path: "post/a/index.html", Kind: "post", Slug: "a"},
+		{Path: "post/old-a/index.html", Kind: "redirect", Slug: "a"},
+		{Path: "collection/go/index.html", Kind: "collection", Slug: "go"},
+		{Path: "author/sam/index.html", Kind: "author", Slug: "sam"},
+	}
+	if len(m.Pages) != len(want) {
+		t.Fatalf("Pages = %+v, want %+v", m.Pages, want)
+	}
+	for i := range want {
+		if m.Pages[i] != want[i] {
+			t.Errorf("Pages[%d] = %+v, want %+v", i, m.Pages[i], want[i])
+		}
+	}
+}
+
+func TestManifestInputKeys(t *testing.T) {
+	dir := newTestSite(t, map[string]string{
+		"posts/a.html": testPost("A", "2024-01-01", "<p>First post.</p>"),
+		"posts/b.html": testPost("B", "2024-01-02", "<p>Second post.</p>"),
+	})
+	// The render key includes today's date, so hold it still.
+	savedNow := now
+	t.Cleanup(func() { now = savedNow })
+	now = func() time.Time { return time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC) }
+
+	build := func(opts buildOptions) map[string]string {
+		t.Helper()
+		if err := buildTestSite(t, opts); err != nil {
+			t.Fatal(err)
+		}
+		m, err := readManifest(filepath.Join(paths.Out, manifestFile))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m.Inputs
+	}
+
+	first := build(buildOptions{})
+	for _, page := range []string{"index.html", "post/a/index.html", "post/b/index.html", "404.html"} {
+		if first[page] == "" {
+			t.Errorf("no input key for %s", page)
+		}
+	}
+
+	second := build(buildOptions{})
+	for page, key := range first {
+		if second[page] != key {
+			t.Errorf("%s: key changed between identical builds", page)
+		}
+	}
+
+	// Age b's page so an incremental build that rewrote it would show.
+	bPage := filepath.Join(paths.Out, "post", "b", "index.html")
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(bPage, old, old); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(dir, "posts", "a.html"), testPost("A", "2024-01-01", "<p>First post, revised.</p>"))
+	postCache = &contentCache{}
+
+	third := build(buildOptions{Incremental: true})
+	if third["post/a/index.html"] == second["post/a/index.html"] {
+		t.Error("post/a/index.html: key unchanged after editing the post")
+	}
+	for _, page := range []string{"post/b/index.html", "404.html"} {
+		if third[page] != second[page] {
+			t.Errorf("%s: key changed after editing another post", page)
+		}
+	}
+	info, err := os.Stat(bPage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(old) {
+		t.Error("post/b/index.html was rewritten by an incremental build although its inputs did not change")
+	}
+}
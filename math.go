@@ -0,0 +1,360 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+)
+
+// renderMath replaces $...$ (inline) and $$...$$ (display) TeX in the text
+// of content with MathML, so pages need no client-side math library. Code
+// blocks, tags and their attribute values are left alone. An escaped
+// \$ outside math becomes a literal dollar sign. An inline $ only opens math
+// when followed by a non-space and only closes it when preceded by a
+// non-space and not followed by a digit, so prices like "$5 and $10" are
+// left alone.
+func renderMath(content string) string {
+	if !strings.Contains(content, "$") {
+		return content
+	}
+	return transformOutsideCode(content, func(s string) string {
+		return transformTextNodes(s, renderMathText)
+	})
+}
+
+// renderMathText renders the math in a run of text that holds no tags.
+func renderMathText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		switch {
+		case strings.HasPrefix(s[i:], `\$`):
+			b.WriteByte('$')
+			i += 2
+		case strings.HasPrefix(s[i:], "$$"):
+			if end := mathEnd(s, i+2, true); end >= 0 {
+				b.WriteString(texToMathML(html.UnescapeString(s[i+2:end]), true))
+				i = end + 2
+				continue
+			}
+			b.WriteString("$$")
+			i += 2
+		case s[i] == '$':
+			if end := mathEnd(s, i+1, false); end >= 0 {
+				b.WriteString(texToMathML(html.UnescapeString(s[i+1:end]), false))
+				i = end + 1
+				continue
+			}
+			b.WriteByte('$')
+			i++
+		default:
+			b.WriteByte(s[i])
+			i++
+		}
+	}
+	return b.String()
+}
+
+// mathEnd returns the index of the delimiter closing the math that starts at
+// s[start], or -1. Math never runs past a "<".
+func mathEnd(s string, start int, display bool) int {
+	if start >= len(s) || (!display && unicode.IsSpace(rune(s[start]))) {
+		return -1
+	}
+	for j := start; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '<':
+			return -1
+		case '$':
+			if display {
+				if strings.HasPrefix(s[j:], "$$") && j > start {
+					return j
+				}
+				return -1
+			}
+			if j == start || unicode.IsSpace(rune(s[j-1])) || (j+1 < len(s) && s[j+1] >= '0' && s[j+1] <= '9') {
+				return -1
+			}
+			return j
+		}
+	}
+	return -1
+}
+
+// texSymbols maps TeX commands to the MathML element and character they
+// render as.
+var texSymbols = map[string][2]string{
+	"alpha": {"mi", "α"}, "beta": {"mi", "β"}, "gamma": {"mi", "γ"}, "delta": {"mi", "δ"},
+	"epsilon": {"mi", "ϵ"}, "varepsilon": {"mi", "ε"}, "zeta": {"mi", "ζ"}, "eta": {"mi", "η"},
+	"theta": {"mi", "θ"}, "iota": {"mi", "ι"}, "kappa": {"mi", "κ"}, "lambda": {"mi", "λ"},
+	"mu": {"mi", "μ"}, "nu": {"mi", "ν"}, "xi": {"mi", "ξ"}, "pi": {"mi", "π"}, "rho": {"mi", "ρ"},
+	"sigma": {"mi", "σ"}, "tau": {"mi", "τ"}, "upsilon": {"mi", "υ"}, "phi": {"mi", "ϕ"},
+	"varphi": {"mi", "φ"}, "chi": {"mi", "χ"}, "psi": {"mi", "ψ"}, "omega": {"mi", "ω"},
+	"Gamma": {"mi", "Γ"}, "Delta": {"mi", "Δ"}, "Theta": {"mi", "Θ"}, "Lambda": {"mi", "Λ"},
+	"Xi": {"mi", "Ξ"}, "Pi": {"mi", "Π"}, "Sigma": {"mi", "Σ"}, "Phi": {"mi", "Φ"},
+	"Psi": {"mi", "Ψ"}, "Omega": {"mi", "Ω"},
+	"infty": {"mi", "∞"}, "partial": {"mi", "∂"}, "nabla": {"mi", "∇"}, "ell": {"mi", "ℓ"},
+	"emptyset": {"mi", "∅"}, "hbar": {"mi", "ℏ"},
+	"cdot": {"mo", "⋅"}, "times": {"mo", "×"}, "div": {"mo", "÷"}, "pm": {"mo", "±"}, "mp": {"mo", "∓"},
+	"leq": {"mo", "≤"}, "le": {"mo", "≤"}, "geq": {"mo", "≥"}, "ge": {"mo", "≥"}, "neq": {"mo", "≠"},
+	"ne": {"mo", "≠"}, "approx": {"mo", "≈"}, "equiv": {"mo", "≡"}, "sim": {"mo", "∼"}, "propto": {"mo", "∝"},
+	"in": {"mo", "∈"}, "notin": {"mo", "∉"}, "subset": {"mo", "⊂"}, "subseteq": {"mo", "⊆"},
+	"supset": {"mo", "⊃"}, "cup": {"mo", "∪"}, "cap": {"mo", "∩"}, "setminus": {"mo", "∖"},
+	"forall": {"mo", "∀"}, "exists": {"mo", "∃"}, "neg": {"mo", "¬"}, "land": {"mo", "∧"}, "lor": {"mo", "∨"},
+	"to": {"mo", "→"}, "rightarrow": {"mo", "→"}, "leftarrow": {"mo", "←"}, "Rightarrow": {"mo", "⇒"},
+	"Leftarrow": {"mo", "⇐"}, "Leftrightarrow": {"mo", "⇔"}, "iff": {"mo", "⟺"}, "implies": {"mo", "⟹"},
+	"mapsto": {"mo", "↦"}, "circ": {"mo", "∘"}, "ast": {"mo", "∗"}, "star": {"mo", "⋆"},
+	"ldots": {"mo", "…"}, "cdots": {"mo", "⋯"}, "dots": {"mo", "…"}, "vdots": {"mo", "⋮"}, "ddots": {"mo", "⋱"},
+	"langle": {"mo", "⟨"}, "rangle": {"mo", "⟩"}, "lfloor": {"mo", "⌊"}, "rfloor": {"mo", "⌋"},
+	"lceil": {"mo", "⌈"}, "rceil": {"mo", "⌉"}, "mid": {"mo", "∣"},
+	"sum": {"mo", "∑"}, "prod": {"mo", "∏"}, "int": {"mo", "∫"}, "oint": {"mo", "∮"},
+	"{": {"mo", "{"}, "}": {"mo", "}"}, "|": {"mo", "‖"}, "$": {"mi", "$"}, "%": {"mi", "%"},
+	"&": {"mo", "&"}, "#": {"mi", "#"}, "_": {"mi", "_"},
+}
+
+// texFunctions are operator names set upright, like \sin.
+var texFunctions = map[string]bool{
+	"sin": true, "cos": true, "tan": true, "cot": true, "sec": true, "csc": true,
+	"arcsin": true, "arccos": true, "arctan": true, "sinh": true, "cosh": true, "tanh": true,
+	"log": true, "ln": true, "exp": true, "lim": true, "max": true, "min": true, "sup": true,
+	"inf": true, "det": true, "dim": true, "gcd": true, "arg": true, "deg": true, "Pr": true,
+}
+
+// texLimits are operators whose scripts sit above and below in display math.
+var texLimits = map[string]bool{"sum": true, "prod": true, "lim": true, "max": true, "min": true, "sup": true, "inf": true}
+
+var texSpaces = map[string]string{",": "0.1667em", ":": "0.2222em", ";": "0.2778em", "!": "-0.1667em", "quad": "1em", "qquad": "2em", " ": "0.25em"}
+
+var texVariants = map[string]string{"mathbf": "bold", "mathit": "italic", "mathrm": "normal", "mathbb": "double-struck", "mathcal": "script", "mathsf": "sans-serif", "mathtt": "monospace"}
+
+// texToMathML converts a practical subset of TeX to MathML: symbols and
+// Greek letters, sub- and superscripts, \frac, \sqrt, \left/\right, \text,
+// font commands and spacing. Unknown commands are shown as an error rather
+// than dropped. The original TeX is kept as an annotation.
+func texToMathML(tex string, display bool) string {
+	p := &texParser{src: tex, display: display}
+	body := p.row(0)
+	open := "<math>"
+	if display {
+		open = `<math display="block">`
+	}
+	return open + "<semantics>" + body + `<annotation encoding="application/x-tex">` + html.EscapeString(strings.TrimSpace(tex)) + "</annotation></semantics></math>"
+}
+
+type texParser struct {
+	src     string
+	pos     int
+	display bool
+}
+
+func (p *texParser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+// row parses atoms until the closing byte (or the end of input, or \right)
+// and returns them as one mrow.
+func (p *texParser) row(closing byte) string {
+	var items []string
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.src) || (closing != 0 && p.src[p.pos] == closing) || strings.HasPrefix(p.src[p.pos:], `\right`) {
+			break
+		}
+		items = append(items, p.scripted())
+	}
+	if len(items) == 1 {
+		return items[0]
+	}
+	return "<mrow>" + strings.Join(items, "") + "</mrow>"
+}
+
+// group parses a braced argument, or a single atom when there are no braces.
+func (p *texParser) group() string {
+	p.skipSpace()
+	if p.pos < len(p.src) && p.src[p.pos] == '{' {
+		p.pos++
+		r := p.row('}')
+		p.pos++
+		return r
+	}
+	if p.pos >= len(p.src) {
+		return "<mrow></mrow>"
+	}
+	a, _ := p.atom()
+	return a
+}
+
+// rawGroup returns the text of a braced argument without parsing it.
+func (p *texParser) rawGroup() string {
+	p.skipSpace()
+	if p.pos >= len(p.src) || p.src[p.pos] != '{' {
+		return ""
+	}
+	depth, start := 0, p.pos+1
+	for ; p.pos < len(p.src); p.pos++ {
+		switch p.src[p.pos] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				p.pos++
+				return p.src[start : p.pos-1]
+			}
+		}
+	}
+	return p.src[start:]
+}
+
+// scripted parses an atom followed by any ^ and _ scripts.
+func (p *texParser) scripted() string {
+	base, limits := p.atom()
+	var sub, sup string
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.src) {
+			break
+		}
+		c := p.src[p.pos]
+		if c == '\'' {
+			p.pos++
+			sup += "<mo>′</mo>"
+			continue
+		}
+		if c != '^' && c != '_' {
+			break
+		}
+		p.pos++
+		if c == '^' {
+			sup += p.group()
+		} else {
+			sub += p.group()
+		}
+	}
+	under, over := "msub", "msup"
+	both := "msubsup"
+	if limits && p.display {
+		under, over, both = "munder", "mover", "munderover"
+	}
+	switch {
+	case sub != "" && sup != "":
+		return fmt.Sprintf("<%s>%s%s%s</%s>", both, base, sub, sup, both)
+	case sub != "":
+		return fmt.Sprintf("<%s>%s%s</%s>", under, base, sub, under)
+	case sup != "":
+		return fmt.Sprintf("<%s>%s%s</%s>", over, base, sup, over)
+	}
+	return base
+}
+
+// atom parses one element and reports whether it takes limits in display
+// math.
+func (p *texParser) atom() (string, bool) {
+	c := p.src[p.pos]
+	switch {
+	case c == '{':
+		return p.group(), false
+	case c == '\\':
+		return p.command()
+	case c == '^' || c == '_':
+		return "<mrow></mrow>", false
+	case c >= '0' && c <= '9' || c == '.':
+		start := p.pos
+		for p.pos < len(p.src) && (p.src[p.pos] >= '0' && p.src[p.pos] <= '9' || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		return "<mn>" + p.src[start:p.pos] + "</mn>", false
+	}
+
+	r := []rune(p.src[p.pos:])[0]
+	p.pos += len(string(r))
+	switch {
+	case r == '-':
+		return "<mo>−</mo>", false
+	case r == '*':
+		return "<mo>∗</mo>", false
+	case unicode.IsLetter(r):
+		return "<mi>" + html.EscapeString(string(r)) + "</mi>", false
+	}
+	return "<mo>" + html.EscapeString(string(r)) + "</mo>", false
+}
+
+func (p *texParser) command() (string, bool) {
+	p.pos++ // backslash
+	start := p.pos
+	for p.pos < len(p.src) && unicode.IsLetter(rune(p.src[p.pos])) {
+		p.pos++
+	}
+	if p.pos == start && p.pos < len(p.src) {
+		p.pos++ // single-character command such as \, or \{
+	}
+	name := p.src[start:p.pos]
+
+	if sym, ok := texSymbols[name]; ok {
+		return fmt.Sprintf("<%s>%s</%s>", sym[0], html.EscapeString(sym[1]), sym[0]), texLimits[name]
+	}
+	if texFunctions[name] {
+		return "<mi>" + name + "</mi>", texLimits[name]
+	}
+	if width, ok := texSpaces[name]; ok {
+		return fmt.Sprintf(`<mspace width="%s"/>`, width), false
+	}
+	if variant, ok := texVariants[name]; ok {
+		return fmt.Sprintf(`<mi mathvariant="%s">%s</mi>`, variant, html.EscapeString(p.rawGroup())), false
+	}
+
+	switch name {
+	case "frac", "dfrac", "tfrac":
+		num := p.group()
+		return "<mfrac>" + num + p.group() + "</mfrac>", false
+	case "sqrt":
+		p.skipSpace()
+		if p.pos < len(p.src) && p.src[p.pos] == '[' {
+			p.pos++
+			index := p.row(']')
+			p.pos++
+			return "<mroot>" + p.group() + index + "</mroot>", false
+		}
+		return "<msqrt>" + p.group() + "</msqrt>", false
+	case "text", "textrm", "operatorname":
+		tag := "mtext"
+		if name == "operatorname" {
+			tag = "mi"
+		}
+		return fmt.Sprintf("<%s>%s</%s>", tag, html.EscapeString(p.rawGroup()), tag), false
+	case "left":
+		open := p.delimiter()
+		inner := p.row(0)
+		if strings.HasPrefix(p.src[p.pos:], `\right`) {
+			p.pos += len(`\right`)
+		}
+		return "<mrow>" + open + inner + p.delimiter() + "</mrow>", false
+	case "\\":
+		return `<mspace linebreak="newline"/>`, false
+	}
+	return "<merror><mtext>" + html.EscapeString(`\`+name) + "</mtext></merror>", false
+}
+
+// delimiter reads the stretchy delimiter after \left or \right; "." is the
+// invisible delimiter.
+func (p *texParser) delimiter() string {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return ""
+	}
+	if p.src[p.pos] == '.' {
+		p.pos++
+		return ""
+	}
+	var d string
+	if p.src[p.pos] == '\\' {
+		d, _ = p.command()
+	} else {
+		d, _ = p.atom()
+	}
+	return strings.Replace(d, "<mo>", `<mo stretchy="true">`, 1)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderMath(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"inline", "<p>Let $x^2$ be</p>", `<p>Let <math><semantics><msup><mi>x</mi><mn>2</mn></msup><annotation encoding="application/x-tex">x^2</annotation></semantics></math> be</p>`},
+		{"display", "<p>$$\\frac{a}{b}$$</p>", `<p><math display="block"><semantics><mfrac><mi>a</mi><mi>b</mi></mfrac><annotation encoding="application/x-tex">\frac{a}{b}</annotation></semantics></math></p>`},
+		{"entity inside math", "$a &lt; b$", `<math><semantics><mrow><mi>a</mi><mo>&lt;</mo><mi>b</mi></mrow><annotation encoding="application/x-tex">a &lt; b</annotation></semantics></math>`},
+		{"escaped dollar", `<p>It costs \$5.</p>`, "<p>It costs $5.</p>"},
+		{"escaped dollars are not math", `\$x\$`, "$x$"},
+		{"prices", "<p>Between $5 and $10 each.</p>", "<p>Between $5 and $10 each.</p>"},
+		{"price after a word", "<p>me$5 or you$6</p>", "<p>me$5 or you$6</p>"},
+		{"space after the opener", "$ x$", "$ x$"},
+		{"space before the closer", "$x $", "$x $"},
+		{"unclosed", "<p>$x and more</p>", "<p>$x and more</p>"},
+		{"empty display", "$$$$", "$$$$"},
+		{"does not span a tag", "<p>$x <em>y</em>$</p>", "<p>$x <em>y</em>$</p>"},
+		{"in code", "<p><code>$x$</code></p>", "<p><code>$x$</code></p>"},
+		{"in a pre block", "<pre><code>echo $HOME $PATH</code></pre>", "<pre><code>echo $HOME $PATH</code></pre>"},
+		{"in an attribute", `<img alt="$x$" src="/a.png">`, `<img alt="$x$" src="/a.png">`},
+		{"attribute with > before math", `<a title="a > $x$" href="/b">$y$</a>`, `<a title="a > $x$" href="/b"><math><semantics><mi>y</mi><annotation encoding="application/x-tex">y</annotation></semantics></math></a>`},
+		{"escaped dollar in an attribute", `<a title="\$x">t</a>`, `<a title="\$x">t</a>`},
+		{"in a script", "<script>let s = `$a$`;</script>", "<script>let s = `$a$`;</script>"},
+		{"in a comment", "<!-- $x$ -->", "<!-- $x$ -->"},
+		{"no dollars", "<p>plain</p>", "<p>plain</p>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderMath(tt.in); got != tt.want {
+				t.Errorf("renderMath(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTexToMathML(t *testing.T) {
+	tests := []struct {
+		tex  string
+		want string
+	}{
+		{`\alpha + \beta`, "<mrow><mi>α</mi><mo>+</mo><mi>β</mi></mrow>"},
+		{`x_i^2`, "<msubsup><mi>x</mi><mi>i</mi><mn>2</mn></msubsup>"},
+		{`\sqrt[3]{8}`, "<mroot><mn>8</mn><mn>3</mn></mroot>"},
+		{`\sin x`, "<mrow><mi>sin</mi><mi>x</mi></mrow>"},
+		{`\text{if } x`, "<mrow><mtext>if </mtext><mi>x</mi></mrow>"},
+		{`\bogus`, `<merror><mtext>\bogus</mtext></merror>`},
+	}
+	for _, tt := range tests {
+		got := texToMathML(tt.tex, false)
+		want := "<math><semantics>" + tt.want + `<annotation encoding="application/x-tex">`
+		if !strings.HasPrefix(got, want) {
+			t.Errorf("texToMathML(%q) = %q, want it to start %q", tt.tex, got, want)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Generated share images use the 1200×630 size Open Graph consumers crop to.
+const (
+	ogImageWidth  = 1200
+	ogImageHeight = 630
+	ogMargin      = 80
+)
+
+// ogPalette matches the badge and collection-card colors in the stylesheets
+// and is indexed by hashColor, so a post's card matches its collection badge.
+var ogPalette = []color.RGBA{
+	{0xc9, 0x59, 0x3a, 0xff},
+	{0x2a, 0x8a, 0x6e, 0xff},
+	{0x58, 0x67, 0xb2, 0xff},
+	{0xb4, 0x4a, 0x76, 0xff},
+	{0x9a, 0x7b, 0x2d, 0xff},
+}
+
+// titleFits lists the title sizes to try, largest first, with the most
+// lines each may take. A title that does not fit the last one is cut off
+// with an ellipsis.
+var titleFits = []struct{ scale, maxLines int }{{8, 3}, {6, 4}}
+
+// writeOGImage renders the share image for post to outputPath as a PNG.
+func writeOGImage(outputPath string, post Post) error {
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, renderOGImage(post)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// renderOGImage draws the site name, the post title wrapped to fit and the
+// publish date on a background keyed to the post's collection, or to the
+// post itself when it has none.
+func renderOGImage(post Post) *image.RGBA {
+	key := post.Collection
+	if key == "" {
+		key = post.Slug
+	}
+	img := image.NewRGBA(image.Rect(0, 0, ogImageWidth, ogImageHeight))
+	draw.Draw(img, img.Bounds(), image.NewUniform(ogPalette[hashColor(key)]), image.Point{}, draw.Src)
+	text := image.NewUniform(color.White)
+	faded := image.NewUniform(color.NRGBA{0xff, 0xff, 0xff, 0xb3})
+
+	drawText(img, ogMargin, ogMargin, 4, asciiFold(site.Title), faded)
+
+	scale, lines := fitTitle(asciiFold(post.Title))
+	lineHeight := (glyphHeight + 3) * scale
+	y := (ogImageHeight - len(lines)*lineHeight) / 2
+	for i, line := range lines {
+		drawText(img, ogMargin, y+i*lineHeight, scale, line, text)
+	}
+
+	if !post.Published.IsZero() {
+		drawText(img, ogMargin, ogImageHeight-ogMargin-glyphHeight*4, 4, asciiFold(post.Date), faded)
+	}
+	return img
+}
+
+// fitTitle picks the largest size at which title wraps into few enough
+// lines, truncating at the smallest size if it never does.
+func fitTitle(title string) (int, []string) {
+	var lines []string
+	for _, fit := range titleFits {
+		lines = wrapText(title, charsPerLine(fit.scale))
+		if len(lines) <= fit.maxLines {
+			return fit.scale, lines
+		}
+	}
+	last := titleFits[len(titleFits)-1]
+	return last.scale, ellipsize(lines, last.maxLines, charsPerLine(last.scale))
+}
+
+func charsPerLine(scale int) int {
+	return (ogImageWidth - 2*ogMargin + scale) / (glyphAdvance * scale)
+}
+
+// wrapText breaks text into lines of at most width characters at spaces,
+// splitting words that are longer than a line on their own.
+func wrapText(text string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		for len(word) > width {
+			if line != "" {
+				lines = append(lines, line)
+				line = ""
+			}
+			lines = append(lines, word[:width])
+			word = word[width:]
+		}
+		switch {
+		case line == "":
+			line = word
+		case len(line)+1+len(word) <= width:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// ellipsize keeps the first max lines and marks the cut with "...", dropping
+// trailing words of the last line to make room.
+func ellipsize(lines []string, max, width int) []string {
+	if len(lines) <= max {
+		return lines
+	}
+	lines = append([]string(nil), lines[:max]...)
+	last := lines[max-1]
+	for len(last)+3 > width {
+		i := strings.LastIndex(last, " ")
+		if i <= 0 {
+			last = last[:width-3]
+			break
+		}
+		last = last[:i]
+	}
+	lines[max-1] = last + "..."
+	return lines
+}
+
+var typographicReplacer = strings.NewReplacer(
+	"‘", "'", "’", "'", "“", `"`, "”", `"`,
+	"–", "-", "—", "-", "…", "...", "\u00a0", " ",
+)
+
+// asciiFold reduces text to the printable ASCII the bitmap font covers:
+// typographic punctuation becomes its plain form, accents are dropped and
+// anything else becomes "?".
+func asciiFold(s string) string {
+	s = typographicReplacer.Replace(s)
+	var b strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+		case r >= ' ' && r <= '~':
+			b.WriteRune(r)
+		case unicode.IsSpace(r):
+			b.WriteByte(' ')
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// drawText renders ASCII text with its top-left corner at (x, y), each font
+// pixel drawn as a scale×scale square.
+func drawText(img draw.Image, x, y, scale int, text string, src image.Image) {
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if c < ' ' || c > '~' {
+			c = '?'
+		}
+		for col, bits := range font5x7[c-' '] {
+			for row := 0; row < glyphHeight; row++ {
+				if bits&(1<<row) == 0 {
+					continue
+				}
+				px, py := x+(i*glyphAdvance+col)*scale, y+row*scale
+				draw.Draw(img, image.Rect(px, py, px+scale, py+scale), src, image.Point{}, draw.Over)
+			}
+		}
+	}
+}
+
+const (
+	glyphHeight  = 7
+	glyphAdvance = 6 // five columns plus one of spacing
+)
+
+// font5x7 is a classic 5×7 bitmap font for printable ASCII, starting at
+// space. Each glyph is five columns; bit 0 is the top row.
+var font5x7 = [95][5]byte{
+	{0x00, 0x00, 0x00, 0x00, 0x00}, // ' '
+	{0x00, 0x00, 0x5f, 0x00, 0x00}, // !
+	{0x00, 0x07, 0x00, 0x07, 0x00}, // "
+	{0x14, 0x7f, 0x14, 0x7f, 0x14}, // #
+	{0x24, 0x2a, 0x7f, 0x2a, 0x12}, // $
+	{0x23, 0x13, 0x08, 0x64, 0x62}, // %
+	{0x36, 0x49, 0x55, 0x22, 0x50}, // &
+	{0x00, 0x05, 0x03, 0x00, 0x00}, // '
+	{0x00, 0x1c, 0x22, 0x41, 0x00}, // (
+	{0x00, 0x41, 0x22, 0x1c, 0x00}, // )
+	{0x14, 0x08, 0x3e, 0x08, 0x14}, // *
+	{0x08, 0x08, 0x3e, 0x08, 0x08}, // +
+	{0x00, 0x50, 0x30, 0x00, 0x00}, // ,
+	{0x08, 0x08, 0x08, 0x08, 0x08}, // -
+	{0x00, 0x60, 0x60, 0x00, 0x00}, // .
+	{0x20, 0x10, 0x08, 0x04, 0x02}, // /
+	{0x3e, 0x51, 0x49, 0x45, 0x3e}, // 0
+	{0x00, 0x42, 0x7f, 0x40, 0x00}, // 1
+	{0x42, 0x61, 0x51, 0x49, 0x46}, // 2
+	{0x21, 0x41, 0x45, 0x4b, 0x31}, // 3
+	{0x18, 0x14, 0x12, 0x7f, 0x10}, // 4
+	{0x27, 0x45, 0x45, 0x45, 0x39}, // 5
+	{0x3c, 0x4a, 0x49, 0x49, 0x30}, // 6
+	{0x01, 0x71, 0x09, 0x05, 0x03}, // 7
+	{0x36, 0x49, 0x49, 0x49, 0x36}, // 8
+	{0x06, 0x49, 0x49, 0x29, 0x1e}, // 9
+	{0x00, 0x36, 0x36, 0x00, 0x00}, // :
+	{0x00, 0x56, 0x36, 0x00, 0x00}, // ;
+	{0x08, 0x14, 0x22, 0x41, 0x00}, // <
+	{0x14, 0x14, 0x14, 0x14, 0x14}, // =
+	{0x00, 0x41, 0x22, 0x14, 0x08}, // >
+	{0x02, 0x01, 0x51, 0x09, 0x06}, // ?
+	{0x32, 0x49, 0x79, 0x41, 0x3e}, // @
+	{0x7e, 0x11, 0x11, 0x11, 0x7e}, // A
+	{0x7f, 0x49, 0x49, 0x49, 0x36}, // B
+	{0x3e, 0x41, 0x41, 0x41, 0x22}, // C
+	{0x7f, 0x41, 0x41, 0x22, 0x1c}, // D
+	{0x7f, 0x49, 0x49, 0x49, 0x41}, // E
+	{0x7f, 0x09, 0x09, 0x09, 0x01}, // F
+	{0x3e, 0x41, 0x49, 0x49, 0x7a}, // G
+	{0x7f, 0x08, 0x08, 0x08, 0x7f}, // H
+	{0x00, 0x41, 0x7f, 0x41, 0x00}, // I
+	{0x20, 0x40, 0x41, 0x3f, 0x01}, // J
+	{0x7f, 0x08, 0x14, 0x22, 0x41}, // K
+	{0x7f, 0x40, 0x40, 0x40, 0x40}, // L
+	{0x7f, 0x02, 0x0c, 0x02, 0x7f}, // M
+	{0x7f, 0x04, 0x08, 0x10, 0x7f}, // N
+	{0x3e, 0x41, 0x41, 0x41, 0x3e}, // O
+	{0x7f, 0x09, 0x09, 0x09, 0x06}, // P
+	{0x3e, 0x41, 0x51, 0x21, 0x5e}, // Q
+	{0x7f, 0x09, 0x19, 0x29, 0x46}, // R
+	{0x46, 0x49, 0x49, 0x49, 0x31}, // S
+	{0x01, 0x01, 0x7f, 0x01, 0x01}, // T
+	{0x3f, 0x40, 0x40, 0x40, 0x3f}, // U
+	{0x1f, 0x20, 0x40, 0x20, 0x1f}, // V
+	{0x3f, 0x40, 0x38, 0x40, 0x3f}, // W
+	{0x63, 0x14, 0x08, 0x14, 0x63}, // X
+	{0x07, 0x08, 0x70, 0x08, 0x07}, // Y
+	{0x61, 0x51, 0x49, 0x45, 0x43}, // Z
+	{0x00, 0x7f, 0x41, 0x41, 0x00}, // [
+	{0x02, 0x04, 0x08, 0x10, 0x20}, // \
+	{0x00, 0x41, 0x41, 0x7f, 0x00}, // ]
+	{0x04, 0x02, 0x01, 0x02, 0x04}, // ^
+	{0x40, 0x40, 0x40, 0x40, 0x40}, // _
+	{0x00, 0x01, 0x02, 0x04, 0x00}, // `
+	{0x20, 0x54, 0x54, 0x54, 0x78}, // a
+	{0x7f, 0x48, 0x44, 0x44, 0x38}, // b
+	{0x38, 0x44, 0x44, 0x44, 0x20}, // c
+	{0x38, 0x44, 0x44, 0x48, 0x7f}, // d
+	{0x38, 0x54, 0x54, 0x54, 0x18}, // e
+	{0x08, 0x7e, 0x09, 0x01, 0x02}, // f
+	{0x0c, 0x52, 0x52, 0x52, 0x3e}, // g
+	{0x7f, 0x08, 0x04, 0x04, 0x78}, // h
+	{0x00, 0x44, 0x7d, 0x40, 0x00}, // i
+	{0x20, 0x40, 0x44, 0x3d, 0x00}, // j
+	{0x7f, 0x10, 0x28, 0x44, 0x00}, // k
+	{0x00, 0x41, 0x7f, 0x40, 0x00}, // l
+	{0x7c, 0x04, 0x18, 0x04, 0x78}, // m
+	{0x7c, 0x08, 0x04, 0x04, 0x78}, // n
+	{0x38, 0x44, 0x44, 0x44, 0x38}, // o
+	{0x7c, 0x14, 0x14, 0x14, 0x08}, // p
+	{0x08, 0x14, 0x14, 0x18, 0x7c}, // q
+	{0x7c, 0x08, 0x04, 0x04, 0x08}, // r
+	{0x48, 0x54, 0x54, 0x54, 0x20}, // s
+	{0x04, 0x3f, 0x44, 0x40, 0x20}, // t
+	{0x3c, 0x40, 0x40, 0x20, 0x7c}, // u
+	{0x1c, 0x20, 0x40, 0x20, 0x1c}, // v
+	{0x3c, 0x40, 0x30, 0x40, 0x3c}, // w
+	{0x44, 0x28, 0x10, 0x28, 0x44}, // x
+	{0x0c, 0x50, 0x50, 0x50, 0x3c}, // y
+	{0x44, 0x64, 0x54, 0x4c, 0x44}, // z
+	{0x00, 0x08, 0x36, 0x41, 0x00}, // {
+	{0x00, 0x00, 0x7f, 0x00, 0x00}, // |
+	{0x00, 0x41, 0x36, 0x08, 0x00}, // }
+	{0x08, 0x04, 0x08, 0x10, 0x08}, // ~
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"os"
+)
+
+// OPML is an OPML 2.0 subscription list: the site feed followed by each
+// collection's, so a reader can follow every series in one import.
+type OPML struct {
+	XMLName xml.Name      `xml:"opml"`
+	Version string        `xml:"version,attr"`
+	Title   string        `xml:"head>title"`
+	Outline []OPMLOutline `xml:"body>outline"`
+}
+
+type OPMLOutline struct {
+	Type        string `xml:"type,attr"`
+	Text        string `xml:"text,attr"`
+	Title       string `xml:"title,attr"`
+	Description string `xml:"description,attr,omitempty"`
+	XMLURL      string `xml:"xmlUrl,attr"`
+	HTMLURL     string `xml:"htmlUrl,attr"`
+}
+
+func newOPML(baseURL string, collections []Collection) OPML {
+	opml := OPML{
+		Version: "2.0",
+		Title:   site.Title + " feeds",
+		Outline: []OPMLOutline{{
+			Type:    "rss",
+			Text:    site.Title,
+			Title:   site.Title,
+			XMLURL:  canonicalURL(baseURL, "/feed.xml"),
+			HTMLURL: canonicalURL(baseURL, "/"),
+		}},
+	}
+	for _, collection := range collections {
+		opml.Outline = append(opml.Outline, OPMLOutline{
+			Type:        "rss",
+			Text:        collection.Title,
+			Title:       collection.Title,
+			Description: collection.DescriptionText,
+			XMLURL:      canonicalURL(baseURL, "/collection/"+collection.Slug+"/feed.xml"),
+			HTMLURL:     canonicalURL(baseURL, "/collection/"+collection.Slug),
+		})
+	}
+	return opml
+}
+
+func buildOPML(outputPath, baseURL string, collections []Collection) error {
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	f.WriteString(xml.Header)
+	encoder := xml.NewEncoder(f)
+	encoder.Indent("", "  ")
+	return encoder.Encode(newOPML(baseURL, collections))
+}
+
+func handleOPML(w http.ResponseWriter, r *http.Request) {
+	collections, err := loadCollections()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/x-opml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(newOPML(requestBaseURL(r), collections)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import "fmt"
+
+// Pagination describes one page of a listing split into pages of a fixed
+// size. Page 1 lives at the listing's own path and page N at
+// <path>/page/N, in the static build and the server alike.
+type Pagination struct {
+	Page       int // from 1
+	TotalPages int
+	PerPage    int
+	Total      int    // items across all pages
+	PrevPath   string // site path of the previous page, empty on the first
+	NextPath   string // site path of the next page, empty on the last
+	start, end int    // the page's items within the full listing
+}
+
+// pagePath is where page n of the listing at path lives.
+func pagePath(path string, n int) string {
+	if n == 1 {
+		return path
+	}
+	return fmt.Sprintf("%s/page/%d", path, n)
+}
+
+// paginate returns page n of total items listed at path. An empty listing
+// still has one empty page; ok is false when n is outside the pages.
+func paginate(total, perPage, n int, path string) (p Pagination, ok bool) {
+	pages := max(1, (total+perPage-1)/perPage)
+	if n < 1 || n > pages {
+		return Pagination{}, false
+	}
+	p = Pagination{
+		Page:       n,
+		TotalPages: pages,
+		PerPage:    perPage,
+		Total:      total,
+		start:      (n - 1) * perPage,
+		end:        min(n*perPage, total),
+	}
+	if n > 1 {
+		p.PrevPath = pagePath(path, n-1)
+	}
+	if n < pages {
+		p.NextPath = pagePath(path, n+1)
+	}
+	return p, true
+}
+
+// collectionPage narrows collection to page n of its posts. The posts keep
+// the CollectionIndex and CollectionTotal of the full collection.
+func collectionPage(collection Collection, n int, baseURL string) (Collection, bool) {
+	path := "/collection/" + collection.Slug
+	p, ok := paginate(collection.PostCount, site.CollectionPageSize, n, path)
+	if !ok {
+		return Collection{}, false
+	}
+	collection.Pagination = p
+	collection.Posts = collection.Posts[p.start:p.end]
+	collection.PageMeta = newPageMeta("collection", baseURL, pagePath(path, n))
+	collection.Feeds = append(collection.Feeds, collectionFeedLink(baseURL, collection))
+	return collection, true
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+)
+
+func previewToken(slug, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(slug))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// validPreview reports whether r carries a valid ?preview= token for slug.
+func validPreview(r *http.Request, slug string) bool {
+	secret := site.PreviewSecret
+	token := r.URL.Query().Get("preview")
+	if secret == "" || token == "" {
+		return false
+	}
+	return hmac.Equal([]byte(token), []byte(previewToken(slug, secret)))
+}
+
+// isHidden reports whether a post with the given draft flag and publish time
+// should be kept out of listings, feeds and the static build.
+func isHidden(draft bool, published time.Time) bool {
+	return draft || published.After(now())
+}
+
+func isPublished(post Post) bool {
+	return !isHidden(post.Draft, post.Published)
+}
+
+func previewURL(slug, baseURL string) (string, error) {
+	secret := site.PreviewSecret
+	if secret == "" {
+		return "", errors.New("PREVIEW_SECRET is not set")
+	}
+	if _, err := loadPost(slug); err != nil {
+		return "", err
+	}
+	return canonicalURL(baseURL, "/post/"+slug) + "?preview=" + previewToken(slug, secret), nil
+}
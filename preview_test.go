@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsHiddenAtTheBoundary(t *testing.T) {
+	at := time.Date(2024, 6, 15, 9, 0, 0, 0, time.UTC)
+	withNow(t, at)
+	tests := []struct {
+		name      string
+		draft     bool
+		published time.Time
+		want      bool
+	}{
+		{"past", false, at.Add(-time.Hour), false},
+		{"exactly now", false, at, false},
+		{"one second ahead", false, at.Add(time.Second), true},
+		{"future date", false, at.AddDate(0, 0, 1), true},
+		{"draft in the past", true, at.Add(-time.Hour), true},
+	}
+	for _, tt := range tests {
+		if got := isHidden(tt.draft, tt.published); got != tt.want {
+			t.Errorf("%s: isHidden(%v, %v) = %v, want %v", tt.name, tt.draft, tt.published, got, tt.want)
+		}
+	}
+}
+
+func TestScheduledPostAppearsOnItsDate(t *testing.T) {
+	newTestSite(t, map[string]string{
+		"posts/live.html":      testPost("Live", "2024-06-01", "<p>x</p>"),
+		"posts/scheduled.html": testPost("Scheduled", "2024-06-15T09:00:00Z", "<p>x</p>"),
+	})
+	visible := func(at time.Time) []string {
+		t.Helper()
+		withNow(t, at)
+		postCache = &contentCache{}
+		posts, err := loadPosts()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var slugs []string
+		for _, p := range posts {
+			if isPublished(p) {
+				slugs = append(slugs, p.Slug)
+			}
+		}
+		return slugs
+	}
+
+	release := time.Date(2024, 6, 15, 9, 0, 0, 0, time.UTC)
+	if got := visible(release.Add(-time.Second)); len(got) != 1 || got[0] != "live" {
+		t.Errorf("a second before release: visible = %v, want [live]", got)
+	}
+	if got := visible(release); len(got) != 2 {
+		t.Errorf("at release: visible = %v, want both posts", got)
+	}
+}
+
+func TestMissingDateMeansNow(t *testing.T) {
+	at := time.Date(2024, 6, 15, 9, 0, 0, 0, time.UTC)
+	withNow(t, at)
+	got, err := parsePostDate("")
+	if err != nil || !got.Equal(at) {
+		t.Errorf(`parsePostDate("") = %v, %v; want %v`, got, err, at)
+	}
+}
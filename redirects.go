@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redirectsFile maps old paths to new ones, for pages that moved other
+// than by a post's `aliases`. Each line holds a source path and a target,
+// either another site path or an absolute URL; blank lines and lines
+// starting with # are ignored:
+//
+//	/post/old-slug          /post/new-slug
+//	/collection/2023-notes  /collection/notes
+//	/talks                  https://talks.example.com/
+//
+// Chains are followed, so a page renamed twice needs no edit to the first
+// line. The server answers a source with a 301; the static build writes a
+// refresh page there, since static hosts cannot redirect.
+const redirectsFile = "redirects.txt"
+
+// loadRedirects reads redirects.txt into a map from source to final
+// target. A site without the file has no redirects.
+func loadRedirects() (map[string]string, error) {
+	f, err := os.Open(paths.content(redirectsFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rules := make(map[string]string)
+	var errs []error
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			errs = append(errs, fmt.Errorf("%s:%d: want a source path and a target, got %q", redirectsFile, line, text))
+			continue
+		}
+		from, to := cleanRedirectPath(fields[0]), fields[1]
+		if !strings.HasPrefix(from, "/") || path.Clean(from) != from {
+			errs = append(errs, fmt.Errorf("%s:%d: source %q is not a clean site path", redirectsFile, line, fields[0]))
+			continue
+		}
+		if !strings.Contains(to, "://") {
+			if !strings.HasPrefix(to, "/") {
+				errs = append(errs, fmt.Errorf("%s:%d: target %q is neither a site path nor an absolute URL", redirectsFile, line, to))
+				continue
+			}
+			to = cleanRedirectPath(to)
+		}
+		if _, ok := rules[from]; ok {
+			errs = append(errs, fmt.Errorf("%s:%d: %s is redirected more than once", redirectsFile, line, from))
+			continue
+		}
+		rules[from] = to
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return resolveRedirects(rules)
+}
+
+// cleanRedirectPath drops the trailing slash, matching the server's one
+// canonical address per page.
+func cleanRedirectPath(p string) string {
+	if p != "/" {
+		p = strings.TrimRight(p, "/")
+	}
+	return p
+}
+
+// resolveRedirects follows each chain of redirects to its final target and
+// reports the chains that loop back on themselves.
+func resolveRedirects(rules map[string]string) (map[string]string, error) {
+	sources := make([]string, 0, len(rules))
+	for from := range rules {
+		sources = append(sources, from)
+	}
+	sort.Strings(sources)
+
+	resolved := make(map[string]string, len(rules))
+	inCycle := make(map[string]bool)
+	var errs []error
+	for _, from := range sources {
+		chain := []string{from}
+		to := rules[from]
+		for {
+			if i := slices.Index(chain, to); i >= 0 {
+				// Report each cycle once, not once per path into it.
+				if !inCycle[to] {
+					errs = append(errs, fmt.Errorf("%s: redirect cycle %s", redirectsFile, strings.Join(append(chain[i:], to), " -> ")))
+					for _, p := range chain[i:] {
+						inCycle[p] = true
+					}
+				}
+				break
+			}
+			next, ok := rules[to]
+			if !ok {
+				resolved[from] = to
+				break
+			}
+			chain = append(chain, to)
+			to = next
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return resolved, nil
+}
+
+// buildRedirects writes a refresh page at each source in redirects.txt.
+// It runs once everything else is built, so a source that would replace a
+// page is an error, and it returns the targets the build did not produce.
+func buildRedirects(rep *buildReport, distDir, baseURL string) ([]string, error) {
+	rules, err := loadRedirects()
+	if err != nil {
+		return nil, err
+	}
+	sources := make([]string, 0, len(rules))
+	for from := range rules {
+		sources = append(sources, from)
+	}
+	sort.Strings(sources)
+
+	var missing []string
+	for _, from := range sources {
+		to := rules[from]
+		dir := filepath.Join(distDir, filepath.FromSlash(from))
+		if builtPath(distDir, from) {
+			return nil, fmt.Errorf("%s: redirect from %s would replace a page the build writes there", redirectsFile, from)
+		}
+		target := to
+		if !strings.Contains(to, "://") {
+			if !builtPath(distDir, to) {
+				missing = append(missing, fmt.Sprintf("%s: %s redirects to %s, which the build does not produce", redirectsFile, from, to))
+			}
+			target = canonicalURL(baseURL, to)
+		}
+		os.MkdirAll(dir, 0755)
+		if err := rep.write("redirect", filepath.Join(dir, "index.html"), paths.content(redirectsFile), func() error {
+			return buildRedirectPage(filepath.Join(dir, "index.html"), target)
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return missing, nil
+}
+
+// builtPath reports whether the build wrote a file at the site path, or an
+// index.html beneath it.
+func builtPath(distDir, sitePath string) bool {
+	sitePath, _, _ = strings.Cut(sitePath, "?")
+	sitePath, _, _ = strings.Cut(sitePath, "#")
+	file := filepath.Join(distDir, filepath.FromSlash(sitePath))
+	if info, err := os.Stat(file); err == nil && !info.IsDir() {
+		return true
+	}
+	_, err := os.Stat(filepath.Join(file, "index.html"))
+	return err == nil
+}
+
+// redirectRules caches redirects.txt for the server, reloading it when the
+// file changes.
+type redirectRules struct {
+	mu    sync.Mutex
+	mtime time.Time
+	size  int64
+	rules map[string]string
+}
+
+var serverRedirects = &redirectRules{}
+
+func (c *redirectRules) get() map[string]string {
+	info, err := os.Stat(paths.content(redirectsFile))
+	if err != nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.mtime.Equal(info.ModTime()) && c.size == info.Size() {
+		return c.rules
+	}
+	c.mtime, c.size = info.ModTime(), info.Size()
+	rules, err := loadRedirects()
+	if err != nil {
+		// Keep the last good rules until the file is fixed.
+		logger.Error("loading redirects", "err", err)
+		return c.rules
+	}
+	c.rules = rules
+	return rules
+}
+
+// redirectHandler answers the sources in redirects.txt with a 301 before
+// next routes the request.
+func redirectHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			p, ok := strings.CutPrefix(r.URL.Path, site.BasePath)
+			if to, found := serverRedirects.get()[cleanRedirectPath(p)]; ok && found {
+				if !strings.Contains(to, "://") {
+					to = sitePath(to)
+				}
+				http.Redirect(w, r, to, http.StatusMovedPermanently)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
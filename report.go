@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// reportMode selects how much a build prints.
+type reportMode int
+
+const (
+	reportNormal  reportMode = iota // one summary line plus warnings
+	reportQuiet                     // errors only
+	reportVerbose                   // a line per output file with its duration
+	reportJSON                      // a single JSON document on stdout
+)
+
+// buildReport records every file a build writes, with the content it came
+// from, its size and how long it took, and prints them according to mode.
+type buildReport struct {
+	mode        reportMode
+	summary     bool // also print a breakdown by kind at the end
+	out         io.Writer
+	outDir      string
+	start       time.Time
+	files       []reportFile
+	recorded    map[string]bool
+	warnings    []string
+	brokenLinks []string
+
+	// Input keys, for incremental builds. previous is nil in a full build.
+	renderKey string
+	inputs    map[string]string
+	previous  map[string]string
+	unchanged map[string]bool
+}
+
+type reportFile struct {
+	Path       string  `json:"path"`
+	Kind       string  `json:"kind"`
+	Source     string  `json:"source,omitempty"`
+	Bytes      int64   `json:"bytes"`
+	DurationMS float64 `json:"duration_ms"`
+	Unchanged  bool    `json:"unchanged,omitempty"` // left as the previous incremental build wrote it
+}
+
+func newBuildReport(mode reportMode, outDir string) *buildReport {
+	return &buildReport{mode: mode, out: os.Stdout, outDir: outDir, start: time.Now(), recorded: make(map[string]bool),
+		inputs: make(map[string]string), unchanged: make(map[string]bool)}
+}
+
+// write runs fn to produce outputPath from source and records the result.
+// Errors name the source so a failing template points at the content that
+// triggered it.
+func (r *buildReport) write(kind, outputPath, source string, fn func() error) error {
+	start := time.Now()
+	if err := fn(); err != nil {
+		if source == "" {
+			return fmt.Errorf("building %s: %w", r.rel(outputPath), err)
+		}
+		return fmt.Errorf("building %s from %s: %w", r.rel(outputPath), source, err)
+	}
+	r.record(kind, outputPath, source, time.Since(start))
+	return nil
+}
+
+func (r *buildReport) record(kind, outputPath, source string, d time.Duration) {
+	var size int64
+	if info, err := os.Stat(outputPath); err == nil {
+		size = info.Size()
+	}
+	file := reportFile{Path: r.rel(outputPath), Kind: kind, Source: source, Bytes: size, DurationMS: float64(d.Microseconds()) / 1000}
+	file.Unchanged = r.unchanged[file.Path]
+	r.files = append(r.files, file)
+	r.recorded[file.Path] = true
+	if r.mode == reportVerbose {
+		took := d.Round(10 * time.Microsecond).String()
+		if file.Unchanged {
+			took = "unchanged"
+		}
+		fmt.Fprintf(r.out, "  %-50s %9s %8s\n", file.Path, formatBytes(size), took)
+	}
+}
+
+func (r *buildReport) rel(outputPath string) string {
+	rel, err := filepath.Rel(r.outDir, outputPath)
+	if err != nil {
+		return filepath.ToSlash(outputPath)
+	}
+	return filepath.ToSlash(rel)
+}
+
+func (r *buildReport) warn(msg string) {
+	r.warnings = append(r.warnings, msg)
+	if r.mode == reportNormal || r.mode == reportVerbose {
+		fmt.Fprintln(r.out, "warning: "+msg)
+	}
+}
+
+func (r *buildReport) brokenLink(problem string) {
+	r.brokenLinks = append(r.brokenLinks, problem)
+	if r.mode == reportNormal || r.mode == reportVerbose {
+		fmt.Fprintln(r.out, "broken link: "+problem)
+	}
+}
+
+func (r *buildReport) count(kind string) int {
+	n := 0
+	for _, f := range r.files {
+		if f.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// recordGenerated records files written as side effects, such as
+// generated image variants.
+func (r *buildReport) recordGenerated() {
+	filepath.WalkDir(r.outDir, func(path string, d fs.DirEntry, err error) error {
+		// A manifest here is the previous build's, about to be replaced.
+		if err == nil && !d.IsDir() && !r.recorded[r.rel(path)] && r.rel(path) != manifestFile {
+			r.record("generated", path, "", 0)
+		}
+		return nil
+	})
+}
+
+// finish records any files not yet accounted for and prints the summary or
+// the JSON document.
+func (r *buildReport) finish() error {
+	r.recordGenerated()
+
+	var total int64
+	for _, f := range r.files {
+		total += f.Bytes
+	}
+	elapsed := time.Since(r.start)
+
+	switch r.mode {
+	case reportJSON:
+		enc := json.NewEncoder(r.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			Output      string       `json:"output"`
+			Posts       int          `json:"posts"`
+			Collections int          `json:"collections"`
+			StaticFiles int          `json:"static_files"`
+			Bytes       int64        `json:"bytes"`
+			DurationMS  float64      `json:"duration_ms"`
+			Files       []reportFile `json:"files"`
+			Warnings    []string     `json:"warnings"`
+			BrokenLinks []string     `json:"broken_links"`
+		}{r.outDir, r.count("post"), r.count("collection"), r.count("static"), total,
+			float64(elapsed.Microseconds()) / 1000, r.files, r.warnings, r.brokenLinks})
+	}
+	if r.mode != reportQuiet {
+		files := fmt.Sprintf("%d files", len(r.files))
+		if len(r.unchanged) > 0 {
+			files += fmt.Sprintf(", %d unchanged", len(r.unchanged))
+		}
+		fmt.Fprintf(r.out, "Built %d posts, %d collections, %d static files (%s, %s) in %s → %s\n",
+			r.count("post"), r.count("collection"), r.count("static"), files, formatBytes(total),
+			elapsed.Round(time.Millisecond), r.outDir)
+	}
+	if r.summary {
+		r.printSummary(total, elapsed)
+	}
+	return nil
+}
+
+// printSummary prints the file count and size for each kind of output,
+// largest first, followed by the totals.
+func (r *buildReport) printSummary(total int64, elapsed time.Duration) {
+	type kindTotal struct {
+		kind  string
+		files int
+		bytes int64
+	}
+	byKind := make(map[string]*kindTotal)
+	var kinds []*kindTotal
+	for _, f := range r.files {
+		k, ok := byKind[f.Kind]
+		if !ok {
+			k = &kindTotal{kind: f.Kind}
+			byKind[f.Kind] = k
+			kinds = append(kinds, k)
+		}
+		k.files++
+		k.bytes += f.Bytes
+	}
+	sort.SliceStable(kinds, func(i, j int) bool { return kinds[i].bytes > kinds[j].bytes })
+
+	fmt.Fprintln(r.out, "\nBuild report")
+	for _, k := range kinds {
+		fmt.Fprintf(r.out, "  %-12s %6d files %10s\n", k.kind, k.files, formatBytes(k.bytes))
+	}
+	fmt.Fprintf(r.out, "  %-12s %6d files %10s\n", "total", len(r.files), formatBytes(total))
+	fmt.Fprintf(r.out, "  %d warnings, %d broken links, built in %s\n", len(r.warnings), len(r.brokenLinks), elapsed.Round(time.Millisecond))
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f kB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"slices"
+	"strings"
+)
+
+// sanitizeTags are the elements post bodies may use. Anything else loses
+// its tags but keeps its text, except the elements in rawTextElements and
+// unsafeElements, which go with their content. SANITIZE_ALLOW_TAGS adds to
+// the list.
+var sanitizeTags = map[string]bool{
+	"a": true, "abbr": true, "article": true, "aside": true, "audio": true,
+	"b": true, "blockquote": true, "br": true, "caption": true, "cite": true,
+	"code": true, "col": true, "colgroup": true, "dd": true, "del": true,
+	"details": true, "dfn": true, "div": true, "dl": true, "dt": true,
+	"em": true, "figcaption": true, "figure": true, "footer": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"header": true, "hr": true, "i": true, "img": true, "ins": true,
+	"kbd": true, "li": true, "mark": true, "ol": true, "p": true,
+	"picture": true, "pre": true, "q": true, "s": true, "samp": true,
+	"section": true, "small": true, "source": true, "span": true,
+	"strong": true, "sub": true, "summary": true, "sup": true, "table": true,
+	"tbody": true, "td": true, "tfoot": true, "th": true, "thead": true,
+	"time": true, "tr": true, "track": true, "u": true, "ul": true,
+	"var": true, "video": true, "wbr": true,
+}
+
+// unsafeElements are dropped along with everything inside them.
+var unsafeElements = map[string]bool{
+	"iframe": true, "object": true, "embed": true, "frame": true, "frameset": true,
+	"applet": true, "form": true, "textarea": true, "select": true, "button": true,
+}
+
+// sanitizeGlobalAttrs are allowed on every element, along with data-* and
+// aria-* attributes. SANITIZE_ALLOW_ATTRS adds to the list.
+var sanitizeGlobalAttrs = map[string]bool{
+	"id": true, "class": true, "title": true, "lang": true, "dir": true, "role": true,
+}
+
+// sanitizeAttrs are the attributes allowed on particular elements.
+var sanitizeAttrs = map[string][]string{
+	"a":          {"href", "name", "rel", "target", "hreflang"},
+	"img":        {"src", "srcset", "sizes", "alt", "width", "height", "loading", "decoding"},
+	"source":     {"src", "srcset", "sizes", "type", "media"},
+	"track":      {"src", "kind", "srclang", "label", "default"},
+	"video":      {"src", "poster", "controls", "width", "height", "loop", "muted", "autoplay", "playsinline", "preload"},
+	"audio":      {"src", "controls", "loop", "muted", "autoplay", "preload"},
+	"blockquote": {"cite"},
+	"q":          {"cite"},
+	"del":        {"cite", "datetime"},
+	"ins":        {"cite", "datetime"},
+	"time":       {"datetime"},
+	"details":    {"open"},
+	"ol":         {"start", "reversed", "type"},
+	"li":         {"value"},
+	"col":        {"span"},
+	"colgroup":   {"span"},
+	"td":         {"colspan", "rowspan", "headers", "align"},
+	"th":         {"colspan", "rowspan", "headers", "scope", "align"},
+}
+
+// urlAttrs hold URLs, which must be relative or use a safe scheme.
+var urlAttrs = map[string]bool{"href": true, "src": true, "srcset": true, "cite": true, "poster": true}
+
+// sanitizeHTML removes the markup in a post body that is not on the
+// allowlist: scripts and other unsafe elements with their content, unknown
+// tags, event handlers and other unlisted attributes, and URLs with
+// schemes such as javascript:. Tags with nothing to remove are kept as
+// written. Comments are dropped. It returns what was removed, once per
+// kind, for validate to report.
+func sanitizeHTML(content string) (string, []string) {
+	var b strings.Builder
+	var removed []string
+	report := func(format string, args ...any) {
+		if msg := fmt.Sprintf(format, args...); !slices.Contains(removed, msg) {
+			removed = append(removed, msg)
+		}
+	}
+
+	s := content
+	for len(s) > 0 {
+		lt := strings.IndexByte(s, '<')
+		if lt < 0 {
+			b.WriteString(s)
+			break
+		}
+		b.WriteString(s[:lt])
+		s = s[lt:]
+
+		name, end, ok := scanTag(s)
+		if !ok {
+			// A "<" that does not open a tag is text to browsers too.
+			b.WriteByte('<')
+			s = s[1:]
+			continue
+		}
+		tag := s[:end]
+		s = s[end:]
+		closing := strings.HasPrefix(name, "/")
+		name = strings.TrimPrefix(name, "/")
+
+		switch {
+		case name == "":
+			// Comments, <!DOCTYPE>, <?xml?> and the like. Metadata and
+			// include comments are consumed before sanitizing, and any
+			// other comment is a place for markup to hide from the
+			// allowlist.
+		case !strings.HasSuffix(tag, ">"):
+			// a tag left open at the end of the input
+			report("removed unterminated <%s> tag", name)
+		case rawTextElements[name] || unsafeElements[name]:
+			if !closing {
+				report("removed <%s> element", name)
+				if rawTextElements[name] {
+					s = skipRawText(s, name)
+				} else {
+					s = skipElement(s, name)
+				}
+			}
+		case !sanitizeTags[name] && !slices.Contains(site.SanitizeAllowTags, name):
+			report("removed <%s> tag", name)
+		case closing:
+			b.WriteString("</" + name + ">")
+		default:
+			b.WriteString(sanitizeStartTag(tag, name, report))
+		}
+	}
+	return b.String(), removed
+}
+
+// sanitizeStartTag drops the attributes of an allowed start tag that are
+// not allowed on it, rebuilding the tag only when one is dropped.
+func sanitizeStartTag(tag, name string, report func(string, ...any)) string {
+	attrs := parseAttrs(tag[1+len(name) : len(tag)-1])
+	kept := attrs[:0:0]
+	for _, attr := range attrs {
+		switch {
+		case !allowedAttr(name, attr.name):
+			report("removed %s attribute from <%s>", attr.name, name)
+		case urlAttrs[attr.name] && !safeURLAttr(attr.name, attr.value):
+			report("removed unsafe %s URL from <%s>", attr.name, name)
+		default:
+			kept = append(kept, attr)
+		}
+	}
+	if len(kept) == len(attrs) {
+		return tag
+	}
+	var b strings.Builder
+	b.WriteString("<" + name)
+	for _, attr := range kept {
+		b.WriteString(" " + attr.name)
+		if attr.hasValue {
+			b.WriteString(`="` + html.EscapeString(attr.value) + `"`)
+		}
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+func allowedAttr(tag, attr string) bool {
+	return sanitizeGlobalAttrs[attr] || strings.HasPrefix(attr, "data-") || strings.HasPrefix(attr, "aria-") ||
+		slices.Contains(sanitizeAttrs[tag], attr) || slices.Contains(site.SanitizeAllowAttrs, attr)
+}
+
+// safeURLAttr reports whether a URL attribute is relative or uses http,
+// https, mailto or tel. Images may also use data:image/ URLs. Each
+// candidate of a srcset is checked.
+func safeURLAttr(attr, value string) bool {
+	if attr != "srcset" {
+		return safeURL(value, attr == "src")
+	}
+	for _, candidate := range strings.Split(value, ",") {
+		if fields := strings.Fields(candidate); len(fields) > 0 && !safeURL(fields[0], false) {
+			return false
+		}
+	}
+	return true
+}
+
+func safeURL(url string, allowDataImage bool) bool {
+	// Browsers ignore whitespace and control characters in schemes, so
+	// "java\tscript:" must not slip through.
+	url = strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, strings.ToLower(url))
+	colon := strings.IndexByte(url, ':')
+	if colon < 0 || strings.ContainsAny(url[:colon], "/?#") {
+		return true
+	}
+	switch url[:colon] {
+	case "http", "https", "mailto", "tel":
+		return true
+	case "data":
+		return allowDataImage && strings.HasPrefix(url, "data:image/") && !strings.HasPrefix(url, "data:image/svg")
+	}
+	return false
+}
+
+type htmlAttr struct {
+	name     string // lower-cased
+	value    string // entities decoded
+	hasValue bool
+}
+
+// parseAttrs splits the attribute text of a start tag the way browsers do:
+// names end at whitespace, "=", "/" or ">", and values are quoted or run to
+// the next whitespace.
+func parseAttrs(s string) []htmlAttr {
+	var attrs []htmlAttr
+	i := 0
+	for {
+		for i < len(s) && (isTagNameEnd(s[i]) || s[i] == '=') && s[i] != '>' {
+			i++
+		}
+		if i >= len(s) || s[i] == '>' {
+			return attrs
+		}
+		start := i
+		for i < len(s) && !isTagNameEnd(s[i]) && s[i] != '=' {
+			i++
+		}
+		attr := htmlAttr{name: strings.ToLower(s[start:i])}
+		j := i
+		for j < len(s) && isSpace(s[j]) {
+			j++
+		}
+		if j < len(s) && s[j] == '=' {
+			j++
+			for j < len(s) && isSpace(s[j]) {
+				j++
+			}
+			attr.hasValue = true
+			switch {
+			case j < len(s) && (s[j] == '"' || s[j] == '\''):
+				end := strings.IndexByte(s[j+1:], s[j])
+				if end < 0 {
+					end = len(s) - j - 1
+				}
+				attr.value = s[j+1 : j+1+end]
+				i = min(len(s), j+1+end+1)
+			default:
+				end := j
+				for end < len(s) && !isSpace(s[end]) && s[end] != '>' {
+					end++
+				}
+				attr.value = s[j:end]
+				i = end
+			}
+			attr.value = html.UnescapeString(attr.value)
+		}
+		attrs = append(attrs, attr)
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// skipElement returns s after the end tag of the element name, counting
+// nested elements of the same name, or "" when it is never closed.
+func skipElement(s, name string) string {
+	depth := 1
+	for len(s) > 0 {
+		lt := strings.IndexByte(s, '<')
+		if lt < 0 {
+			return ""
+		}
+		s = s[lt:]
+		tagName, end, ok := scanTag(s)
+		if !ok {
+			s = s[1:]
+			continue
+		}
+		s = s[end:]
+		switch tagName {
+		case name:
+			depth++
+		case "/" + name:
+			if depth--; depth == 0 {
+				return s
+			}
+		}
+	}
+	return ""
+}
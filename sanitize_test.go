@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeHTML(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    string
+		removed int // how many kinds of removal are reported
+	}{
+		{"script", `<p>a</p><script>alert(1)</script><p>b</p>`, `<p>a</p><p>b</p>`, 1},
+		{"unclosed script", `<p>a</p><script>alert(1)`, `<p>a</p>`, 1},
+		{"event handler", `<img src="a.png" onerror="alert(1)" alt="a">`, `<img src="a.png" alt="a">`, 1},
+		{"javascript url", `<a href="javascript:alert(1)">x</a>`, `<a>x</a>`, 1},
+		{"javascript url with whitespace", `<a href=" java&#x09;script:alert(1)">x</a>`, `<a>x</a>`, 1},
+		{"data url link", `<a href="data:text/html,<script>alert(1)</script>">x</a>`, `<a>x</a>`, 1},
+		{"data image", `<img src="data:image/png;base64,AAAA">`, `<img src="data:image/png;base64,AAAA">`, 0},
+		{"iframe with content", `<iframe src="https://evil.example"><p>x</p></iframe>ok`, `ok`, 1},
+		{"unknown tag keeps text", `<blink>hi</blink>`, `hi`, 1},
+		{"allowed markup is untouched", `<p class="x"><a href="/post/a" title='t'>a</a></p>`, `<p class="x"><a href="/post/a" title='t'>a</a></p>`, 0},
+
+		// Comments end where browsers end them, and are dropped.
+		{"comment", `a<!-- note -->b`, `ab`, 0},
+		{"abrupt empty comment", `<!--><script>alert(1)</script>-->`, `-->`, 1},
+		{"abrupt empty comment with dash", `<!---><img src=x onerror=alert(1)>-->`, `<img src="x">-->`, 1},
+		{"bang close", `<!-- a --!><img src=x onerror=alert(1)>-->`, `<img src="x">-->`, 1},
+		{"unclosed comment", `a<!-- <script>alert(1)</script>`, `a`, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, removed := sanitizeHTML(tt.in)
+			if got != tt.want {
+				t.Errorf("sanitizeHTML(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			if len(removed) != tt.removed {
+				t.Errorf("sanitizeHTML(%q) reported %q, want %d removals", tt.in, removed, tt.removed)
+			}
+			for _, bad := range []string{"<script", "onerror", "javascript:", "<iframe"} {
+				if strings.Contains(strings.ToLower(got), bad) {
+					t.Errorf("sanitizeHTML(%q) = %q, still contains %s", tt.in, got, bad)
+				}
+			}
+		})
+	}
+}
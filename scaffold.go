@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// runNew implements `new post "Title"` and `new collection "Title"`.
+// Flags may appear before or after the title.
+func runNew(args []string) error {
+	if len(args) == 0 {
+		return errors.New(`usage: new post|collection "Title" [--collection=slug] [--tags=a,b] [--edit]`)
+	}
+	kind := args[0]
+
+	flags := flag.NewFlagSet("new "+kind, flag.ContinueOnError)
+	collection := flags.String("collection", "", "collection slug for the new post")
+	tags := flags.String("tags", "", "comma-separated tags for the new post")
+	edit := flags.Bool("edit", false, "open the new file in $EDITOR")
+
+	var positional []string
+	rest := args[1:]
+	for {
+		if err := flags.Parse(rest); err != nil {
+			return err
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		positional = append(positional, flags.Arg(0))
+		rest = flags.Args()[1:]
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("new %s: expected exactly one title argument", kind)
+	}
+	title := positional[0]
+
+	var path string
+	var err error
+	switch kind {
+	case "post":
+		path, err = newPost(title, *collection, splitList(*tags))
+	case "collection":
+		path, err = newCollection(title)
+	default:
+		return fmt.Errorf("new: unknown kind %q, expected post or collection", kind)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(path)
+	if *edit {
+		return openInEditor(path)
+	}
+	return nil
+}
+
+func newPost(title, collection string, tags []string) (string, error) {
+	slug := generateID(title)
+	if slug == "" {
+		return "", fmt.Errorf("title %q does not produce a usable slug", title)
+	}
+	if dir := paths.content("posts", slug); fileExists(dir) {
+		return "", fmt.Errorf("%s already exists", dir)
+	}
+
+	if err := checkMetaValues(map[string]string{"title": title, "collection": collection, "tags": strings.Join(tags, ", ")}); err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<!-- title: %s -->\n", title)
+	fmt.Fprintf(&b, "<!-- date: %s -->\n", time.Now().In(site.Location).Format("2006-01-02"))
+	// Empty keys are written out so the available fields are easy to fill in
+	for _, key := range []string{"updated", "description", "author"} {
+		fmt.Fprintf(&b, "<!-- %s:  -->\n", key)
+	}
+	fmt.Fprintf(&b, "<!-- collection: %s -->\n", collection)
+	b.WriteString("<!-- order:  -->\n")
+	fmt.Fprintf(&b, "<!-- tags: %s -->\n", strings.Join(tags, ", "))
+	for _, key := range []string{"image", "draft", "pinned", "aliases", "layout", "lang", "translation-of", "toc", "eager-images", "styles", "scripts"} {
+		fmt.Fprintf(&b, "<!-- %s:  -->\n", key)
+	}
+	b.WriteString("\n<p>Start writing here.</p>\n")
+
+	path := paths.content("posts", slug+".html")
+	return path, writeNewFile(path, b.String())
+}
+
+func newCollection(title string) (string, error) {
+	slug := generateID(title)
+	if slug == "" {
+		return "", fmt.Errorf("title %q does not produce a usable slug", title)
+	}
+
+	if err := checkMetaValues(map[string]string{"title": title}); err != nil {
+		return "", err
+	}
+
+	content := fmt.Sprintf("<!-- title: %s -->\n<!-- parent:  -->\n<!-- planned:  -->\n<!-- status:  -->\n\n<p>Describe the collection here.</p>\n", title)
+	path := paths.content("collections", slug+".html")
+	return path, writeNewFile(path, content)
+}
+
+// checkMetaValues rejects values that would end their metadata comment
+// early and spill the rest into the body.
+func checkMetaValues(values map[string]string) error {
+	for key, value := range values {
+		if strings.Contains(value, "-->") {
+			return fmt.Errorf("%s %q contains \"-->\", which would end its metadata comment", key, value)
+		}
+	}
+	return nil
+}
+
+// writeNewFile creates path with content, refusing to overwrite anything.
+func writeNewFile(path, content string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("%s already exists", path)
+		}
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(content)
+	return err
+}
+
+func openInEditor(path string) error {
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		return errors.New("--edit: $EDITOR is not set")
+	}
+	cmd := exec.Command(editor, path)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// maxSearchTextLength caps the body text stored per post so the index stays
+// small enough to download on page load.
+const maxSearchTextLength = 5000
+
+// SearchEntry is one record of search-index.json, which is a JSON array of
+// these objects:
+//
+//	{"slug": "...", "title": "...", "date": "2006-01-02", "tags": ["..."], "text": "..."}
+//
+// date is the raw publish date, tags is always an array (possibly empty) and
+// text is the post body with markup stripped, truncated at a word boundary.
+// Front-end code relies on this shape, so fields may be added but not renamed.
+type SearchEntry struct {
+	Slug  string   `json:"slug"`
+	Title string   `json:"title"`
+	Date  string   `json:"date"`
+	Tags  []string `json:"tags"`
+	Text  string   `json:"text"`
+}
+
+func newSearchIndex(posts []Post) []SearchEntry {
+	entries := make([]SearchEntry, 0, len(posts))
+	for _, post := range posts {
+		tags := post.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		entries = append(entries, SearchEntry{
+			Slug:  post.Slug,
+			Title: post.Title,
+			Date:  post.RawDate,
+			Tags:  tags,
+			Text:  truncateText(stripHTML(withoutHeadingAnchors(string(post.Content))), maxSearchTextLength),
+		})
+	}
+	return entries
+}
+
+// truncateText shortens s to at most limit bytes, cutting at the last word
+// boundary before the limit.
+func truncateText(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	s = s[:limit]
+	if i := strings.LastIndexByte(s, ' '); i > 0 {
+		s = s[:i]
+	}
+	return strings.ToValidUTF8(s, "")
+}
+
+func writeSearchIndex(w io.Writer, posts []Post) error {
+	return json.NewEncoder(w).Encode(newSearchIndex(posts))
+}
+
+func buildSearchIndex(outputPath string, posts []Post) error {
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return writeSearchIndex(f, posts)
+}
+
+func handleSearchIndex(w http.ResponseWriter, r *http.Request) {
+	posts, err := loadPosts()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := writeSearchIndex(w, posts); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
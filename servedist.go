@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// distContentTypes overrides the extension-based Content-Type for files
+// whose generic type is wrong or varies by platform, matching what the
+// server sends.
+var distContentTypes = map[string]string{
+	"feed.xml": "application/rss+xml; charset=utf-8",
+	".xml":     "application/xml; charset=utf-8",
+	".json":    "application/json; charset=utf-8",
+	".txt":     "text/plain; charset=utf-8",
+}
+
+// serveDist serves a finished build the way a static host would, for
+// checking it before deploy.
+func serveDist(dir, port string) error {
+	if _, err := os.Stat(filepath.Join(dir, "index.html")); err != nil {
+		return err
+	}
+	server := &http.Server{
+		Addr:              ":" + port,
+		Handler:           compressHandler(distHandler(dir)),
+		ReadHeaderTimeout: site.ReadTimeout,
+		ReadTimeout:       site.ReadTimeout,
+		WriteTimeout:      site.WriteTimeout,
+		IdleTimeout:       site.IdleTimeout,
+	}
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return err
+	}
+	logger.Info("serving build", "addr", listener.Addr().String(), "url", "http://localhost:"+port+sitePath("/"), "dir", dir)
+	return server.Serve(listener)
+}
+
+// distHandler maps a URL to a built file like resolveDistPath does for the
+// link checker: the file itself, or index.html inside a directory. Anything
+// else gets 404.html with a 404 status.
+func distHandler(dir string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rel, ok := resolveDistPath(dir, path.Clean(r.URL.Path))
+		status := http.StatusOK
+		if !ok {
+			rel, status = "404.html", http.StatusNotFound
+		}
+		f, err := os.Open(filepath.Join(dir, filepath.FromSlash(rel)))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if contentType, ok := distContentTypes[path.Base(rel)]; ok {
+			w.Header().Set("Content-Type", contentType)
+		} else if contentType, ok := distContentTypes[path.Ext(rel)]; ok {
+			w.Header().Set("Content-Type", contentType)
+		}
+		if status == http.StatusNotFound {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(status)
+			f.WriteTo(w)
+			return
+		}
+		setAssetCaching(w, r)
+		if strings.HasPrefix(rel, "api/") {
+			setCORS(w, r)
+		}
+		http.ServeContent(w, r, rel, info.ModTime(), f)
+	})
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+type URLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []SitemapURL `xml:"url"`
+}
+
+type SitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// newSitemap lists every public page. A post's lastmod is its updated date
+// when it has one, and listing pages take the newest lastmod of the posts
+// they show.
+func newSitemap(baseURL string, posts []Post, collections []Collection, authors []Author) URLSet {
+	sitemap := URLSet{XMLNS: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+	add := func(path string, posts []Post) {
+		var newest time.Time
+		for _, post := range posts {
+			if t := lastUpdated(post); t.After(newest) {
+				newest = t
+			}
+		}
+		url := SitemapURL{Loc: canonicalURL(baseURL, path)}
+		if !newest.IsZero() {
+			url.LastMod = newest.Format("2006-01-02")
+		}
+		sitemap.URLs = append(sitemap.URLs, url)
+	}
+
+	add("/", posts)
+	for _, post := range posts {
+		add("/post/"+post.Slug, []Post{post})
+	}
+	add("/collections", posts)
+	add("/archive", inLanguage(posts, site.Language))
+	for _, collection := range collections {
+		add("/collection/"+collection.Slug, collection.Posts)
+	}
+	for _, author := range authors {
+		add("/author/"+author.Slug, authorPosts(author.Slug, posts))
+	}
+	return sitemap
+}
+
+func buildSitemap(outputPath, baseURL string, posts []Post, collections []Collection, authors []Author) error {
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	f.WriteString(xml.Header)
+	encoder := xml.NewEncoder(f)
+	encoder.Indent("", "  ")
+	return encoder.Encode(newSitemap(baseURL, posts, collections, authors))
+}
+
+func handleSitemap(w http.ResponseWriter, r *http.Request) {
+	posts, err := loadPosts()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	collections, err := loadCollections()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	authors, err := loadAuthors()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(newSitemap(requestBaseURL(r), posts, collections, authors)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// newRobots is the robots.txt used when the site has none of its own: allow
+// everything except draft previews and point crawlers at the sitemap, or,
+// for NO_INDEX staging builds, disallow everything.
+func newRobots(baseURL string) string {
+	if site.NoIndex {
+		return "User-agent: *\nDisallow: /\n"
+	}
+	// Previews are /post/<slug>?preview=<token>; the token keeps them
+	// private, this keeps a leaked link out of search results.
+	return fmt.Sprintf("User-agent: *\nAllow: /\nDisallow: %s\n\nSitemap: %s\n",
+		sitePath("/*?preview="), canonicalURL(baseURL, "/sitemap.xml"))
+}
+
+// buildRobots writes the generated robots.txt. The build only calls it when
+// the site has no robots.txt of its own, which is copied as is.
+func buildRobots(outputPath, baseURL string) error {
+	return os.WriteFile(outputPath, []byte(newRobots(baseURL)), 0644)
+}
+
+// handleRobots serves the site's robots.txt if it has one, and the generated
+// default otherwise.
+func handleRobots(w http.ResponseWriter, r *http.Request) {
+	if _, err := os.Stat(paths.content("robots.txt")); err == nil {
+		http.ServeFile(w, r, paths.content("robots.txt"))
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(newRobots(requestBaseURL(r))))
+}
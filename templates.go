@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pageTemplates maps each page type to the template rendered inside
+// layout.html.
+var pageTemplates = map[string]string{
+	"index":       "index.html",
+	"post":        "post.html",
+	"collections": "collections.html",
+	"collection":  "collection.html",
+	"author":      "author.html",
+	"archive":     "archive.html",
+	"notfound":    "404.html",
+}
+
+// templateRegistry holds the parsed layout+content template for every page
+// type, keyed by the content template's file name, so the server does not
+// re-parse them on each request. Post layout overrides are parsed the first
+// time they are used. With reload set, it re-parses everything when any
+// template file's mtime changes.
+type templateRegistry struct {
+	reload bool
+
+	mu        sync.RWMutex
+	templates map[string]*template.Template
+	mtimes    map[string]time.Time
+}
+
+var pages = &templateRegistry{}
+
+// load parses every page template, failing on the first broken one.
+func (reg *templateRegistry) load() error {
+	templates := make(map[string]*template.Template, len(pageTemplates))
+	mtimes := make(map[string]time.Time)
+	for pageType, name := range pageTemplates {
+		tmpl, err := parseContentTemplate(name, mtimes)
+		if err != nil {
+			return fmt.Errorf("parsing %s templates: %w", pageType, err)
+		}
+		templates[name] = tmpl
+	}
+
+	reg.mu.Lock()
+	reg.templates, reg.mtimes = templates, mtimes
+	reg.mu.Unlock()
+	return nil
+}
+
+// stale reports whether any template file changed since the last load.
+func (reg *templateRegistry) stale() bool {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	for file, mtime := range reg.mtimes {
+		if info, err := os.Stat(file); err != nil || !info.ModTime().Equal(mtime) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseContentTemplate parses layout.html with the named content template
+// and records both files' mtimes.
+func parseContentTemplate(name string, mtimes map[string]time.Time) (*template.Template, error) {
+	files := []string{paths.template("layout.html"), paths.template(name)}
+	tmpl, err := parseTemplates(files...)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			mtimes[file] = info.ModTime()
+		}
+	}
+	return tmpl, nil
+}
+
+func (reg *templateRegistry) get(pageType string) (*template.Template, error) {
+	name, ok := pageTemplates[pageType]
+	if !ok {
+		return nil, fmt.Errorf("no template for page type %q", pageType)
+	}
+	return reg.lookup(name)
+}
+
+// lookup returns layout.html combined with the content template name,
+// parsing and caching it if it is not one of the page templates.
+func (reg *templateRegistry) lookup(name string) (*template.Template, error) {
+	if reg.reload && reg.stale() {
+		if err := reg.load(); err != nil {
+			return nil, err
+		}
+		logger.Info("templates reloaded")
+	}
+	reg.mu.RLock()
+	tmpl, ok := reg.templates[name]
+	reg.mu.RUnlock()
+	if ok {
+		return tmpl, nil
+	}
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if tmpl, ok := reg.templates[name]; ok {
+		return tmpl, nil
+	}
+	tmpl, err := parseContentTemplate(name, reg.mtimes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", name, err)
+	}
+	reg.templates[name] = tmpl
+	return tmpl, nil
+}
+
+// render executes the page into a buffer first, so a failure halfway through
+// becomes a clean 500 instead of a truncated page sent with 200 OK.
+func (reg *templateRegistry) render(w http.ResponseWriter, pageType string, data any) {
+	tmpl, err := reg.get(pageType)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	reg.execute(w, tmpl, data)
+}
+
+// renderPost renders a post with the content template it names in its
+// `layout` metadata, or post.html. Posts can be long, so the response
+// carries an ETag of the rendered page and a Content-Length: a client that
+// already has the page gets a 304, and an interrupted download can resume
+// with a range request.
+func (reg *templateRegistry) renderPost(w http.ResponseWriter, r *http.Request, post Post) {
+	tmpl, err := reg.lookup(postTemplate(post))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, "layout", post); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("ETag", contentETag(buf.Bytes()))
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(buf.Bytes()))
+}
+
+func (reg *templateRegistry) execute(w http.ResponseWriter, tmpl *template.Template, data any) {
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, "layout", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	buf.WriteTo(w)
+}
+
+// postTemplate is the content template a post renders with: the one named
+// by its `layout` metadata when that file exists, post.html otherwise.
+func postTemplate(post Post) string {
+	if post.Layout != "" {
+		if _, err := os.Stat(paths.template(post.Layout + ".html")); err == nil {
+			return post.Layout + ".html"
+		}
+	}
+	return "post.html"
+}
+
+var layoutNameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// parseLayoutName checks a `layout` metadata value. It must be a bare file
+// name under templates/, with or without .html, so it cannot reach outside
+// the directory or replace the shared layout.
+func parseLayoutName(value string) (string, error) {
+	name := strings.TrimSuffix(value, ".html")
+	if !layoutNameRegex.MatchString(name) || name == "layout" {
+		return "", fmt.Errorf("layout %q is not a template name under templates/", value)
+	}
+	return name, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// Set at link time, e.g.
+//
+//	go build -ldflags "-X main.version=1.4.0 -X main.commit=$(git rev-parse --short HEAD) -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// A plain `go build` or `go run .` leaves the defaults, though the commit
+// still comes from the VCS stamp when Go recorded one.
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildDate = "unknown"
+)
+
+// BuildInfo identifies the binary that rendered a page, for the footer and
+// the head's generator and build-date meta tags. Date is left out of JSON
+// so it does not count as a change to the pages of an incremental build.
+type BuildInfo struct {
+	Version string
+	Commit  string
+	Date    string `json:"-"` // when the page was built: the static build's start, or the binary's build date in server mode
+}
+
+// currentBuild is copied into every PageMeta. buildStatic replaces Date
+// with its own start time, so a deploy can be checked for freshness.
+var currentBuild = BuildInfo{Version: version, Commit: vcsCommit(), Date: buildDate}
+
+// vcsCommit is the commit from -ldflags, or else the short revision Go
+// stamps into binaries built inside a git checkout.
+func vcsCommit() string {
+	if commit != "unknown" {
+		return commit
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return commit
+	}
+	revision, modified := "", false
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if revision == "" {
+		return commit
+	}
+	revision = revision[:min(len(revision), 7)]
+	if modified {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+func stampBuild(builtAt time.Time) {
+	currentBuild.Date = builtAt.UTC().Format(time.RFC3339)
+}
+
+func printVersion() {
+	fmt.Printf("version: %s\ncommit:  %s\nbuilt:   %s\n", version, currentBuild.Commit, buildDate)
+}